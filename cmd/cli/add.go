@@ -9,20 +9,57 @@ import (
 )
 
 func NewAddCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "add <file|directory>",
+	var opts gogit.AddOptions
+	var patch bool
+
+	cmd := &cobra.Command{
+		Use:   "add [-A | -u | -p | -N] [<pathspec>...]",
 		Short: "Add a file or directory to the gogit repository",
-		Long: `Adds the specified file or directory to the staging area (index).
+		Long: `Adds the specified files or directories to the staging area (index).
 When a directory is specified, it recursively adds all files within that
-directory, excluding the .gogit directory itself.`,
-		Args: cobra.ExactArgs(1),
+directory, excluding the .gogit directory itself.
+
+Pathspecs may use wildcards ("*.go", "src/**") and magic such as
+":(exclude)generated/*" (or ":!generated/*") to leave paths out.
+
+With -A (--all) tracked files that were deleted from the working tree are
+removed from the index as well. With -u (--update) only files already in the
+index are staged, including their deletions. Both default to the whole
+working tree when no path is given.
+
+With -p (--patch) each hunk of the changes to tracked files is shown and
+can be staged on its own, split into smaller hunks or edited.
+
+With -N (--intent-to-add) untracked files are recorded in the index without
+their content. They are reported by status and diff as new files and must be
+added for real before they can be committed.`,
+		Args: cobra.ArbitraryArgs,
 		Run: func(_ *cobra.Command, args []string) {
-			pathToAdd := args[0]
+			if patch {
+				if err := gogit.AddPatch(args, os.Stdin, os.Stdout); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					exit(1)
+				}
+				return
+			}
+
+			if len(args) == 0 && !opts.All && !opts.Update {
+				fmt.Fprintln(os.Stderr, "Nothing specified, nothing added.")
+				exit(1)
+			}
 
-			if err := gogit.Add(pathToAdd); err != nil {
+			if err := gogit.Add(args, opts); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVarP(&opts.All, "all", "A", false, "Add, modify and remove index entries to match the working tree")
+	cmd.Flags().BoolVarP(&opts.Update, "update", "u", false, "Stage modifications and deletions of tracked files only")
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Interactively choose hunks to stage")
+	cmd.Flags().BoolVarP(&opts.IntentToAdd, "intent-to-add", "N", false, "Record only the fact that the path will be added later")
+	cmd.MarkFlagsMutuallyExclusive("all", "update", "patch", "intent-to-add")
+
+	return cmd
 }
@@ -10,14 +10,40 @@ import (
 
 func NewBranchCmd() *cobra.Command {
 	var deleteFlag bool
+	var upstream string
+	var unsetUpstream bool
 
 	cmd := &cobra.Command{
-		Use:   "branch [name]",
+		Use:   "branch [-d] [-u <upstream> | --unset-upstream] [name]",
 		Short: "Manage branches in the gogit repository",
 		Long: `Create, list, delete, and switch branches in the gogit repository.
-This command allows you to manage branches effectively.`,
+This command allows you to manage branches effectively.
+
+With -u (--set-upstream-to) the branch (the current one when no name is
+given) tracks another branch, such as "origin/main" or a local branch;
+status then shows how many commits each side is ahead. --unset-upstream
+removes that configuration.`,
 		Args: cobra.MaximumNArgs(1),
-		Run: func(_ *cobra.Command, args []string) {
+		Run: func(cmd *cobra.Command, args []string) {
+			branch := ""
+			if len(args) > 0 {
+				branch = args[0]
+			}
+			if cmd.Flags().Changed("set-upstream-to") {
+				if err := gogit.SetUpstream(branch, upstream); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					exit(1)
+				}
+				return
+			}
+			if unsetUpstream {
+				if err := gogit.UnsetUpstream(branch); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					exit(1)
+				}
+				return
+			}
+
 			if deleteFlag {
 				if len(args) < 1 {
 					fmt.Fprintln(os.Stderr, "error: branch name required for deletion")
@@ -41,6 +67,9 @@ This command allows you to manage branches effectively.`,
 	}
 
 	cmd.Flags().BoolVarP(&deleteFlag, "delete", "d", false, "Delete a branch")
+	cmd.Flags().StringVarP(&upstream, "set-upstream-to", "u", "", "Set the upstream of the branch")
+	cmd.Flags().BoolVar(&unsetUpstream, "unset-upstream", false, "Remove the upstream of the branch")
+	cmd.MarkFlagsMutuallyExclusive("delete", "set-upstream-to", "unset-upstream")
 
 	return cmd
 }
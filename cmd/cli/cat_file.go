@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewCatFileCmd() *cobra.Command {
+	var showType, showSize, pretty, exists bool
+
+	cmd := &cobra.Command{
+		Use:   "cat-file (-t | -s | -p | -e) <object>",
+		Short: "Show the content, type or size of an object",
+		Long: `Prints an object of the repository, named by a revision, a full or
+abbreviated hash, or <rev>:<path> for a file of a commit. It does not need a
+working tree, so it can inspect a bare repository.
+
+-p prints the content, -t the type (blob, tree or commit) and -s the size in
+bytes. -e prints nothing and exits with status 1 when the object does not
+exist.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			mode := gogit.CatFilePretty
+			switch {
+			case showType:
+				mode = gogit.CatFileType
+			case showSize:
+				mode = gogit.CatFileSize
+			case exists:
+				mode = gogit.CatFileExists
+			}
+
+			found, err := gogit.CatFile(args[0], mode)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(128)
+			}
+			if !found {
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&showType, "type", "t", false, "Show the type of the object")
+	cmd.Flags().BoolVarP(&showSize, "size", "s", false, "Show the size of the object")
+	cmd.Flags().BoolVarP(&pretty, "pretty", "p", false, "Show the content of the object")
+	cmd.Flags().BoolVarP(&exists, "exists", "e", false, "Exit with status 1 when the object does not exist")
+	cmd.MarkFlagsMutuallyExclusive("type", "size", "pretty", "exists")
+	cmd.MarkFlagsOneRequired("type", "size", "pretty", "exists")
+
+	return cmd
+}
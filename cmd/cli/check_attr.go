@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewCheckAttrCmd() *cobra.Command {
+	var opts gogit.CheckAttrOptions
+
+	cmd := &cobra.Command{
+		Use:   "check-attr [-a] [--stdin] [<attr>...] [--] <path>...",
+		Short: "Debug the attributes of paths",
+		Long: `Prints the attributes of each path, as given by the .gogitattributes files,
+.gogit/info/attributes and core.attributesFile, one "<path>: <attr>: <state>"
+line per attribute. The state is "set", "unset", "unspecified" or the value
+of the attribute.
+
+Without "--" the first argument is the attribute and the others are paths;
+with -a every specified attribute of the paths is shown.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var attrs, paths []string
+			switch dash := cmd.ArgsLenAtDash(); {
+			case dash >= 0:
+				attrs, paths = args[:dash], args[dash:]
+			case opts.All || len(args) == 0:
+				paths = args
+			default:
+				attrs, paths = args[:1], args[1:]
+			}
+
+			if err := gogit.CheckAttr(attrs, paths, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(128)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.All, "all", "a", false, "Show all the attributes specified for the paths")
+	cmd.Flags().BoolVar(&opts.Stdin, "stdin", false, "Read the paths from standard input")
+
+	return cmd
+}
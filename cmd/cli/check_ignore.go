@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewCheckIgnoreCmd() *cobra.Command {
+	var opts gogit.CheckIgnoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "check-ignore [-v] [-n] [--stdin] <path>...",
+		Short: "Debug the ignore rules",
+		Long: `Prints each given path that is ignored by a .gogitignore file,
+.gogit/info/exclude or core.excludesFile.
+
+With -v the source file, line number and pattern that decided each path are
+printed as well, including "!" patterns that re-include a path. The exit
+status is 0 when at least one path is ignored and 1 otherwise.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(_ *cobra.Command, args []string) {
+			ignored, err := gogit.CheckIgnore(args, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(128)
+			}
+			if !ignored {
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show the matching pattern of each path")
+	cmd.Flags().BoolVarP(&opts.NonMatching, "non-matching", "n", false, "Show paths that match no pattern too")
+	cmd.Flags().BoolVar(&opts.Stdin, "stdin", false, "Read the paths from standard input")
+
+	return cmd
+}
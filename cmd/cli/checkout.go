@@ -20,7 +20,7 @@ If the branch does not exist, it can be created with the -b flag.`,
 		Run: func(_ *cobra.Command, args []string) {
 			if err := gogit.CheckoutBranch(args[0], bFlag); err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewCleanCmd() *cobra.Command {
+	var opts gogit.CleanOptions
+
+	cmd := &cobra.Command{
+		Use:   "clean [-n] [-f] [-i] [-d] [-x | -X] [-e <pattern>] [<pathspec>...]",
+		Short: "Remove untracked files from the working tree",
+		Long: `Removes the files that are not tracked in the index, under the current
+directory or the given pathspecs. Ignored files are kept unless -x is given.
+
+Nothing is deleted without -f (--force) or -i (--interactive): by default,
+as with -n (--dry-run), the files that would be removed are only listed.
+
+With -d untracked directories are removed too. With -x the ignore rules are
+not used, so ignored files are removed as well; with -X only ignored files
+are removed. Patterns given with -e are added to the ignore rules, which
+keeps matching files even with -x.
+
+With -i a menu lets you clean, filter the list by pattern, select items by
+number or confirm each removal.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(_ *cobra.Command, args []string) {
+			if err := gogit.Clean(args, opts, os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "Only show what would be removed")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Remove the files")
+	cmd.Flags().BoolVarP(&opts.Interactive, "interactive", "i", false, "Choose interactively what to remove")
+	cmd.Flags().BoolVarP(&opts.Directories, "directories", "d", false, "Remove untracked directories too")
+	cmd.Flags().BoolVarP(&opts.NoIgnore, "no-ignore", "x", false, "Do not use the ignore rules")
+	cmd.Flags().BoolVarP(&opts.OnlyIgnored, "only-ignored", "X", false, "Remove only ignored files")
+	cmd.Flags().StringArrayVarP(&opts.Exclude, "exclude", "e", nil, "Add an ignore pattern")
+
+	return cmd
+}
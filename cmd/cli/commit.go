@@ -17,7 +17,7 @@ func NewCommitCmd() *cobra.Command {
 		Run: func(_ *cobra.Command, _ []string) {
 			if err := gogit.AddCommit(&msg); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
@@ -25,7 +25,7 @@ func NewCommitCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&msg, "message", "m", "", "Mensaje del commit")
 	if err := cmd.MarkFlagRequired("message"); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	return cmd
@@ -13,9 +13,14 @@ func NewConfigCmd() *cobra.Command {
 		Short: "Configure user name and email (like git config --global)",
 		Long: `Set global configuration values for the current user.
 
+user.name and user.email are stored in ~/.gogitconfig. Any other variable
+is stored in the repository configuration (.gogit/config), which takes
+precedence over the global one.
+
 Examples:
   gogit config user.name "John Doe"
-  gogit config user.email "john@example.com"`,
+  gogit config user.email "john@example.com"
+  gogit config branch.main.merge refs/heads/develop`,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) != 2 {
 				fmt.Println("Usage: gogit config <key> <value>")
@@ -23,6 +28,7 @@ Examples:
 				fmt.Println("  user.name   Your full name")
 				fmt.Println("  user.email  Your email address")
 				fmt.Println("  list  	   Your list config")
+				fmt.Println("  <section>.<key>  Any other variable, stored in .gogit/config")
 				fmt.Println()
 				if err := cmd.Usage(); err != nil {
 					fmt.Printf("Error: %v\n", err)
@@ -54,8 +60,11 @@ Examples:
 				}
 
 			default:
-				fmt.Printf("Unknown config key: %s\n", key)
-				fmt.Println("Supported keys are: user.name, user.email")
+				// Other variables, such as branch.<name>.merge, belong to the repository.
+				if err := gogit.SetRepoConfig(key, value); err != nil {
+					fmt.Printf("Error saving %s: %v\n", key, err)
+					return
+				}
 			}
 		},
 	}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewDiffCmd() *cobra.Command {
+	var opts gogit.DiffOptions
+	var renames *renameFlags
+
+	cmd := &cobra.Command{
+		Use:   "diff [--cached] [--name-status] [-M[=<n>] | -C[=<n>] | --no-renames] [[--] <pathspec>...]",
+		Short: "Show changes between the index and the working tree",
+		Long: `Shows the changes in the working tree that are not yet staged, in unified
+diff format. With --cached (or --staged) shows the changes staged for the
+next commit instead, relative to HEAD.
+
+Renamed files are shown as renames when their content is at least 50%
+similar; -M=<n> changes the threshold and -C[=<n>] detects copies too. The
+default comes from diff.renames.
+
+Hunks are colored when the output is a terminal. Set color.ui to "always"
+or "never" to force the choice.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			if opts.Renames, err = renames.options(cmd, "diff.renames"); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+
+			if err := gogit.Diff(args, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.Cached, "cached", false, "Show the staged changes relative to HEAD")
+	cmd.Flags().BoolVar(&opts.Cached, "staged", false, "Synonym for --cached")
+	cmd.Flags().BoolVar(&opts.NameStatus, "name-status", false, "Show only the names and status of the changed files")
+	renames = addRenameFlags(cmd)
+
+	return cmd
+}
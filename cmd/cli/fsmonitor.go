@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewFsmonitorDaemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fsmonitor--daemon <start | run | stop | status>",
+		Short: "Watch the working tree to speed up status and add",
+		Long: `Runs a daemon that watches the working tree (with inotify, on Linux) and
+tells status and add which paths changed since their previous run, so they
+do not have to read every file again.
+
+The daemon is only queried when core.fsmonitor is set to true. When it is
+not running, the whole working tree is walked as usual.`,
+	}
+
+	run := func(fn func() error) func(*cobra.Command, []string) {
+		return func(_ *cobra.Command, _ []string) {
+			if err := fn(); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		}
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "start",
+			Short: "Start the daemon in the background",
+			Args:  cobra.NoArgs,
+			Run:   run(gogit.StartFsmonitorDaemon),
+		},
+		&cobra.Command{
+			Use:   "run",
+			Short: "Run the daemon in the foreground",
+			Args:  cobra.NoArgs,
+			Run:   run(gogit.RunFsmonitorDaemon),
+		},
+		&cobra.Command{
+			Use:   "stop",
+			Short: "Stop the daemon",
+			Args:  cobra.NoArgs,
+			Run:   run(gogit.StopFsmonitorDaemon),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Report whether the daemon is running",
+			Args:  cobra.NoArgs,
+			Run: func(_ *cobra.Command, _ []string) {
+				if !gogit.FsmonitorDaemonStatus() {
+					exit(1)
+				}
+			},
+		},
+	)
+
+	return cmd
+}
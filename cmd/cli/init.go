@@ -9,20 +9,36 @@ import (
 )
 
 func NewInitCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "init [directory]",
+	var bare bool
+
+	cmd := &cobra.Command{
+		Use:   "init [--bare] [directory]",
 		Short: "Creates a new gogit repository",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Creates a new gogit repository in the current directory.
+
+With --bare the repository is created in the given directory (the current
+one by default) without a working tree: HEAD, the objects, the refs and the
+config are at its top level. Such a repository is meant to be shared; the
+commands that need a working tree refuse to run in it.`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(_ *cobra.Command, args []string) {
 			targetDir := "."
 			if len(args) > 0 {
 				targetDir = args[0]
 			}
 
-			if err := gogit.InitRepo(targetDir); err != nil {
+			initRepo := gogit.InitRepo
+			if bare {
+				initRepo = gogit.InitBareRepo
+			}
+			if err := initRepo(targetDir); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVar(&bare, "bare", false, "Create a repository without a working tree")
+
+	return cmd
 }
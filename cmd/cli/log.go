@@ -9,14 +9,34 @@ import (
 )
 
 func NewLogCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "log",
+	var opts gogit.LogOptions
+	var renames *renameFlags
+
+	cmd := &cobra.Command{
+		Use:   "log [--name-status] [-M[=<n>] | -C[=<n>] | --no-renames] [[--] <pathspec>...]",
 		Short: "Show commits logs",
-		Run: func(_ *cobra.Command, _ []string) {
-			if err := gogit.LogRepo(); err != nil {
+		Long: `Shows the history of the current branch. When pathspecs are given,
+only the commits that changed a matching path are shown.
+
+With --name-status each commit is followed by the paths it changed and
+their status letter. Renames are detected as in diff: "R085" is a rename
+with 85% similar content, "C" a copy with -C.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			if opts.Renames, err = renames.options(cmd, "diff.renames"); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+
+			if err := gogit.LogRepo(args, opts); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVar(&opts.NameStatus, "name-status", false, "Show the names and status of the changed files")
+	renames = addRenameFlags(cmd)
+
+	return cmd
 }
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewLsFilesCmd() *cobra.Command {
+	var opts gogit.LsFilesOptions
+
+	cmd := &cobra.Command{
+		Use:   "ls-files [-v] [<pathspec>...]",
+		Short: "Show the files in the index",
+		Long: `Lists the paths tracked in the index.
+
+With -v each path is preceded by a tag: "H" for a regular entry and "S" for
+a skip-worktree entry, in lowercase when the entry is assume-unchanged.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(_ *cobra.Command, args []string) {
+			if err := gogit.LsFiles(args, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show the index flags of each file")
+
+	return cmd
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewMvCmd() *cobra.Command {
+	var opts gogit.MoveOptions
+
+	cmd := &cobra.Command{
+		Use:   "mv [-f] [-k] <source>... <destination>",
+		Short: "Move or rename a file or a directory",
+		Long: `Renames a tracked file or directory, updating both the working tree and
+the staging area (index).
+
+With several sources the destination must be an existing directory.
+An existing destination file is only overwritten with -f, and -k skips
+the sources that cannot be moved instead of aborting.`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			sources, destination := args[:len(args)-1], args[len(args)-1]
+
+			if err := gogit.Move(sources, destination, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Force renaming even if the destination exists")
+	cmd.Flags().BoolVarP(&opts.SkipErrors, "skip-errors", "k", false, "Skip move or rename actions which would lead to an error")
+
+	return cmd
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+// renameFlags holds the rename detection options shared by status, diff and
+// log: -M[=<n>], -C[=<n>] and --no-renames. The threshold follows an equal
+// sign; an attached "-M90" is not understood by the flag parser.
+type renameFlags struct {
+	findRenames string
+	findCopies  string
+	noRenames   bool
+}
+
+func addRenameFlags(cmd *cobra.Command) *renameFlags {
+	f := &renameFlags{}
+	cmd.Flags().StringVarP(&f.findRenames, "find-renames", "M", "", "Detect renames, with an optional similarity threshold as in -M=90% (default 50%)")
+	cmd.Flags().Lookup("find-renames").NoOptDefVal = "50%"
+	cmd.Flags().StringVarP(&f.findCopies, "find-copies", "C", "", "Detect copies as well as renames, with an optional similarity threshold as in -C=90%")
+	cmd.Flags().Lookup("find-copies").NoOptDefVal = "50%"
+	cmd.Flags().BoolVar(&f.noRenames, "no-renames", false, "Turn off rename detection")
+	return f
+}
+
+// options returns the detection asked for on the command line, or else the
+// one set by the first configured variable of configNames.
+func (f *renameFlags) options(cmd *cobra.Command, configNames ...string) (gogit.RenameOptions, error) {
+	if f.noRenames {
+		return gogit.RenameOptions{}, nil
+	}
+
+	switch {
+	case cmd.Flags().Changed("find-copies"):
+		threshold, err := gogit.ParseRenameThreshold(f.findCopies)
+		return gogit.RenameOptions{Renames: true, Copies: true, Threshold: threshold}, err
+	case cmd.Flags().Changed("find-renames"):
+		threshold, err := gogit.ParseRenameThreshold(f.findRenames)
+		return gogit.RenameOptions{Renames: true, Threshold: threshold}, err
+	default:
+		return gogit.ConfigRenameOptions(configNames...)
+	}
+}
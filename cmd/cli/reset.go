@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewResetCmd() *cobra.Command {
+	var soft, mixed, hard bool
+
+	cmd := &cobra.Command{
+		Use:   "reset [--soft | --mixed | --hard] [<rev>] [[--] <paths>...]",
+		Short: "Reset current HEAD to the specified state",
+		Long: `Moves the current branch to <rev> (HEAD by default).
+
+  --soft   only moves the branch; the index and working tree are untouched
+  --mixed  also resets the index to <rev> (the default)
+  --hard   also resets the working tree, discarding local changes
+
+When paths are given, only their index entries are reset to their state
+in <rev>, which unstages them without moving the branch.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			rev, paths, err := splitRevAndPaths(args, cmd.ArgsLenAtDash())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(128)
+			}
+
+			if len(paths) > 0 {
+				if soft || hard {
+					fmt.Fprintln(os.Stderr, "fatal: Cannot do soft or hard reset with paths.")
+					exit(1)
+				}
+				err = gogit.ResetPaths(rev, paths)
+			} else {
+				mode := gogit.ResetMixed
+				if soft {
+					mode = gogit.ResetSoft
+				} else if hard {
+					mode = gogit.ResetHard
+				}
+				err = gogit.Reset(rev, mode)
+			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&soft, "soft", false, "Only move the branch to <rev>")
+	cmd.Flags().BoolVar(&mixed, "mixed", false, "Reset the index but not the working tree (default)")
+	cmd.Flags().BoolVar(&hard, "hard", false, "Reset the index and the working tree")
+	cmd.MarkFlagsMutuallyExclusive("soft", "mixed", "hard")
+
+	return cmd
+}
+
+// splitRevAndPaths separates "[<rev>] [--] <paths>..." arguments. Without a
+// "--" the first argument is a path only if it does not resolve to a revision
+// but exists in the working tree. Before a "--" there is at most one
+// argument, the revision.
+func splitRevAndPaths(args []string, dashAt int) (string, []string, error) {
+	switch {
+	case dashAt > 1:
+		return "", nil, fmt.Errorf("fatal: too many revisions before '--': %s", strings.Join(args[:dashAt], " "))
+	case dashAt == 1:
+		return args[0], args[dashAt:], nil
+	case dashAt == 0:
+		return gogit.HEAD, args, nil
+	}
+
+	if len(args) == 0 {
+		return gogit.HEAD, nil, nil
+	}
+	if _, err := gogit.ResolveRevision(args[0]); err != nil {
+		if _, statErr := os.Lstat(gogit.UserPath(args[0])); statErr == nil {
+			return gogit.HEAD, args, nil
+		}
+	}
+	return args[0], args[1:], nil
+}
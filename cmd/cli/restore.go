@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewRestoreCmd() *cobra.Command {
+	var opts gogit.RestoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "restore [--staged] [--worktree] [--source=<rev>] <pathspec>...",
+		Short: "Restore working tree files or unstage changes",
+		Long: `Restores the specified paths in the working tree (the default) with
+their content in the index, or in the index (--staged) with their content
+in HEAD. Both can be restored at once, in which case the source is HEAD.
+
+--source restores from the tree of any commit instead. Files that do not
+exist in the source are removed. A revision given where a path is expected,
+as in "restore main file.txt", is refused rather than taken for a path.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if cmd.Flags().Changed("source") && opts.Source == "" {
+				fmt.Fprintln(os.Stderr, "fatal: --source requires a revision")
+				exit(1)
+			}
+
+			if err := gogit.Restore(args, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Staged, "staged", "S", false, "Restore the index")
+	cmd.Flags().BoolVarP(&opts.Worktree, "worktree", "W", false, "Restore the working tree (default)")
+	cmd.Flags().StringVarP(&opts.Source, "source", "s", "", "Restore the files from the given revision")
+
+	return cmd
+}
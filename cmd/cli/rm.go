@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewRmCmd() *cobra.Command {
+	var opts gogit.RemoveOptions
+
+	cmd := &cobra.Command{
+		Use:   "rm [-r] [--cached] [-f] <pathspec>...",
+		Short: "Remove files from the working tree and from the index",
+		Long: `Removes the specified files from the staging area (index) and from the
+working tree. With --cached the files are only untracked and left on disk.
+
+Files with uncommitted changes are refused unless -f is given, so that no
+content is lost. Directories require -r.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := gogit.Remove(args, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Recursive, "recursive", "r", false, "Allow recursive removal when a directory is given")
+	cmd.Flags().BoolVar(&opts.Cached, "cached", false, "Only remove from the index, keep the working tree files")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Override the up-to-date check")
+
+	return cmd
+}
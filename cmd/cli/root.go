@@ -1,18 +1,53 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
 func NewRootCmd() *cobra.Command {
+	var directories []string
+
 	rootCmd := &cobra.Command{
-		Use:   "gogit",
+		Use:   "gogit [-C <path>]... <command>",
 		Short: "gogit - a simplified Git replica written in Go",
 		Long: `gogit is a minimalist version control system
 	created as a learning project to understand the fundamental
-	concepts of Git.`,
+	concepts of Git.
+
+	gogit -C <path> <command> runs the command as if it was started
+	in <path>. The repository is looked for in the current directory
+	and its parents; GOGIT_DIR and GOGIT_WORK_TREE name the repository
+	and its working tree instead.`,
+		// -C is only parsed before the command name, where status, diff
+		// and log cannot take it for their copy detection.
+		TraverseChildren: true,
+		// The repository is found, and the current directory changed to the
+		// root of its working tree, before any command runs.
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			if err := changeDirectories(directories); err != nil {
+				return err
+			}
+			if noRepositoryCommands[topLevelName(cmd)] {
+				return nil
+			}
+			if err := gogit.SetupRepository(); err != nil {
+				return err
+			}
+			if worktreeCommands[topLevelName(cmd)] {
+				return gogit.RequireWorktree()
+			}
+			return nil
+		},
 	}
 
+	rootCmd.Flags().StringArrayVarP(&directories, "directory", "C", nil, "Run as if gogit was started in <path> (repeatable, each relative to the previous one)")
+
 	rootCmd.AddCommand(
 		NewInitCmd(),
 		NewAddCmd(),
@@ -22,7 +57,83 @@ func NewRootCmd() *cobra.Command {
 		NewConfigCmd(),
 		NewCheckoutCmd(),
 		NewBranchCmd(),
+		NewRmCmd(),
+		NewMvCmd(),
+		NewResetCmd(),
+		NewRestoreCmd(),
+		NewDiffCmd(),
+		NewSparseCheckoutCmd(),
+		NewUpdateIndexCmd(),
+		NewLsFilesCmd(),
+		NewCheckIgnoreCmd(),
+		NewCheckAttrCmd(),
+		NewCleanCmd(),
+		NewFsmonitorDaemonCmd(),
+		NewWorktreeCmd(),
+		NewCatFileCmd(),
 	)
 
+	// Long-running content filters are stopped once the command is done.
+	cobra.OnFinalize(gogit.StopFilterProcesses)
+
 	return rootCmd
 }
+
+// worktreeCommands read or write the working tree or the index, and cannot
+// run in a bare repository.
+var worktreeCommands = map[string]bool{
+	"add":               true,
+	"commit":            true,
+	"status":            true,
+	"checkout":          true,
+	"rm":                true,
+	"mv":                true,
+	"reset":             true,
+	"restore":           true,
+	"diff":              true,
+	"sparse-checkout":   true,
+	"update-index":      true,
+	"ls-files":          true,
+	"check-ignore":      true,
+	"check-attr":        true,
+	"clean":             true,
+	"fsmonitor--daemon": true,
+}
+
+// noRepositoryCommands run without looking for a repository: init creates
+// one in the current directory, even inside another repository.
+var noRepositoryCommands = map[string]bool{
+	"init": true,
+}
+
+// topLevelName returns the name of cmd, or of the command it is a
+// subcommand of; "" for the root itself.
+func topLevelName(cmd *cobra.Command) string {
+	for ; cmd.HasParent(); cmd = cmd.Parent() {
+		if !cmd.Parent().HasParent() {
+			return cmd.Name()
+		}
+	}
+	return ""
+}
+
+// changeDirectories applies the -C options in order, each path being
+// relative to the previous one. An empty path changes nothing.
+func changeDirectories(dirs []string) error {
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.Chdir(dir); err != nil {
+			return fmt.Errorf("fatal: cannot change to '%s': %w", dir, errors.Unwrap(err))
+		}
+	}
+	return nil
+}
+
+// exit stops the filter processes, which the finalizer misses when a command
+// exits from its Run function, and ends the program with code.
+func exit(code int) {
+	gogit.StopFilterProcesses()
+	os.Exit(code)
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewSparseCheckoutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sparse-checkout <init | set | add | list | disable>",
+		Short: "Reduce the working tree to a subset of directories",
+		Long: `Restricts the working tree to a set of directories (cone mode). The files
+at the top of the repository and the files directly inside the parents of
+each directory are always checked out.
+
+Paths outside the cone are removed from the working tree and marked
+skip-worktree in the index: status does not report them as deleted and
+checkout does not write them. The directories are stored in
+.gogit/info/sparse-checkout.`,
+	}
+
+	run := func(fn func(args []string) error) func(*cobra.Command, []string) {
+		return func(_ *cobra.Command, args []string) {
+			if err := fn(args); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		}
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "init",
+			Short: "Enable sparse checkout with only the top-level files",
+			Args:  cobra.NoArgs,
+			Run:   run(func([]string) error { return gogit.SparseCheckoutInit() }),
+		},
+		&cobra.Command{
+			Use:   "set <directory>...",
+			Short: "Check out exactly the given directories",
+			Args:  cobra.ArbitraryArgs,
+			Run:   run(gogit.SparseCheckoutSet),
+		},
+		&cobra.Command{
+			Use:   "add <directory>...",
+			Short: "Add directories to the sparse checkout",
+			Args:  cobra.MinimumNArgs(1),
+			Run:   run(gogit.SparseCheckoutAdd),
+		},
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the directories of the sparse checkout",
+			Args:  cobra.NoArgs,
+			Run:   run(func([]string) error { return gogit.SparseCheckoutList() }),
+		},
+		&cobra.Command{
+			Use:   "disable",
+			Short: "Restore the full working tree",
+			Args:  cobra.NoArgs,
+			Run:   run(func([]string) error { return gogit.SparseCheckoutDisable() }),
+		},
+	)
+
+	return cmd
+}
@@ -9,14 +9,80 @@ import (
 )
 
 func NewStatusCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "status",
+	var opts gogit.StatusOptions
+	var short bool
+	var porcelain string
+	var untracked string
+	var renames *renameFlags
+
+	cmd := &cobra.Command{
+		Use:   "status [--short | --porcelain[=v1|v2]] [-b] [-z] [-u[=<mode>]] [--ignored] [<pathspec>...]",
 		Short: "Show commit status",
-		Run: func(_ *cobra.Command, _ []string) {
-			if err := gogit.StatusRepo(); err != nil {
+		Long: `Shows the changes staged for the next commit, the changes in the working
+tree that are not staged, and the untracked files.
+
+--short prints one "XY path" line per file, where X is the state of the
+index and Y the state of the working tree, in color on a terminal (see
+color.ui). --porcelain prints the same format without colors, and --porcelain=v2 adds the modes and hashes of each
+file; both are meant for scripts. -b adds a branch header and -z ends each
+entry with NUL instead of a newline (it implies --porcelain).
+
+Untracked directories are shown as "dir/" rather than file by file;
+--untracked-files=all (-u) lists every file and --untracked-files=no hides
+them; the short form takes the mode after an equal sign, as in -u=no. The
+default comes from status.showUntrackedFiles. --ignored lists the
+ignored files as well, after the untracked ones.
+
+Staged files that were renamed are shown as renames when their content is
+at least 50% similar (see -M); -C reports copies as well. The default comes
+from status.renames, then diff.renames.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			switch {
+			case cmd.Flags().Changed("porcelain"):
+				switch porcelain {
+				case "v1", "1":
+					opts.Format = gogit.StatusFormatPorcelain
+				case "v2", "2":
+					opts.Format = gogit.StatusFormatPorcelainV2
+				default:
+					fmt.Fprintf(os.Stderr, "fatal: unsupported porcelain version '%s'\n", porcelain)
+					exit(1)
+				}
+			case short:
+				opts.Format = gogit.StatusFormatShort
+			case opts.NullTerminated:
+				opts.Format = gogit.StatusFormatPorcelain
+			}
+
+			var err error
+			if cmd.Flags().Changed("untracked-files") {
+				if opts.Untracked, err = gogit.ParseUntrackedMode(untracked); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					exit(1)
+				}
+			}
+			if opts.Renames, err = renames.options(cmd, "status.renames", "diff.renames"); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+
+			if err := gogit.StatusRepo(args, opts); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVarP(&short, "short", "s", false, "Give the output in the short format")
+	cmd.Flags().StringVar(&porcelain, "porcelain", "", "Give the output in an easy-to-parse format (v1 or v2)")
+	cmd.Flags().Lookup("porcelain").NoOptDefVal = "v1"
+	cmd.Flags().BoolVarP(&opts.Branch, "branch", "b", false, "Show the branch in the short formats")
+	cmd.Flags().BoolVarP(&opts.NullTerminated, "null", "z", false, "Terminate entries with NUL")
+	cmd.Flags().StringVarP(&untracked, "untracked-files", "u", "", "Show untracked files: no, normal or all")
+	cmd.Flags().Lookup("untracked-files").NoOptDefVal = "all"
+	cmd.Flags().BoolVar(&opts.Ignored, "ignored", false, "Show ignored files as well")
+	cmd.MarkFlagsMutuallyExclusive("short", "porcelain")
+	renames = addRenameFlags(cmd)
+
+	return cmd
 }
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewUpdateIndexCmd() *cobra.Command {
+	var opts gogit.UpdateIndexOptions
+
+	cmd := &cobra.Command{
+		Use:   "update-index [--[no-]assume-unchanged] [--[no-]skip-worktree] <path>...",
+		Short: "Set or clear the flags of index entries",
+		Long: `Marks tracked files so that their working tree copy is left alone.
+
+--assume-unchanged tells gogit that the file does not change: status, diff
+and add trust the index instead of reading the file. --skip-worktree means
+the working tree copy must be kept as it is, e.g. a config file edited
+locally that must never be staged; checkout refuses to overwrite it.
+
+Use --no-assume-unchanged and --no-skip-worktree to clear the flags, and
+"gogit ls-files -v" to see them.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := gogit.UpdateIndex(args, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.AssumeUnchanged, "assume-unchanged", false, "Mark the files as not changing")
+	cmd.Flags().BoolVar(&opts.NoAssumeUnchanged, "no-assume-unchanged", false, "Clear the assume-unchanged flag")
+	cmd.Flags().BoolVar(&opts.SkipWorktree, "skip-worktree", false, "Leave the working tree files alone")
+	cmd.Flags().BoolVar(&opts.NoSkipWorktree, "no-skip-worktree", false, "Clear the skip-worktree flag")
+
+	return cmd
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/gogit/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+func NewWorktreeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "worktree <add | list | remove | prune | lock | unlock>",
+		Short: "Manage several working trees",
+		Long: `Checks out more than one branch at a time. Each linked worktree has its own
+HEAD and index in .gogit/worktrees/<name>, and a .gogit file pointing back
+to the repository; objects, branches and the configuration are shared. A
+branch can only be checked out in one working tree at a time.`,
+	}
+
+	run := func(fn func(args []string) error) func(*cobra.Command, []string) {
+		return func(_ *cobra.Command, args []string) {
+			if err := fn(args); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				exit(1)
+			}
+		}
+	}
+
+	var addOpts gogit.WorktreeAddOptions
+	add := &cobra.Command{
+		Use:   "add [-b <new-branch>] [--detach] [-f] [--lock [--reason <string>]] <path> [<commit-ish>]",
+		Short: "Create a worktree and check out a branch or commit in it",
+		Long: `Creates a worktree at <path> and checks out <commit-ish> in it: a branch, or
+any other revision with a detached HEAD. Without <commit-ish> the branch
+named after the last component of <path> is checked out, and created at
+HEAD when it does not exist.`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: run(func(args []string) error {
+			commitish := ""
+			if len(args) > 1 {
+				commitish = args[1]
+			}
+			return gogit.WorktreeAdd(args[0], commitish, addOpts)
+		}),
+	}
+	add.Flags().StringVarP(&addOpts.NewBranch, "branch", "b", "", "Create a new branch")
+	add.Flags().BoolVarP(&addOpts.Detach, "detach", "d", false, "Detach HEAD in the new worktree")
+	add.Flags().BoolVarP(&addOpts.Force, "force", "f", false, "Check out a branch even if another worktree has it")
+	add.Flags().BoolVar(&addOpts.Lock, "lock", false, "Lock the new worktree")
+	add.Flags().StringVar(&addOpts.LockReason, "reason", "", "Reason for the lock")
+	add.MarkFlagsMutuallyExclusive("branch", "detach")
+
+	var porcelain bool
+	list := &cobra.Command{
+		Use:   "list [--porcelain]",
+		Short: "List the working trees",
+		Args:  cobra.NoArgs,
+		Run:   run(func([]string) error { return gogit.WorktreeList(porcelain) }),
+	}
+	list.Flags().BoolVar(&porcelain, "porcelain", false, "Give the output in an easy-to-parse format")
+
+	var force int
+	remove := &cobra.Command{
+		Use:   "remove [-f] <worktree>",
+		Short: "Remove a worktree",
+		Long: `Removes a linked worktree. One with modified or untracked files is only
+removed with -f, and a locked one with -f -f.`,
+		Args: cobra.ExactArgs(1),
+		Run:  run(func(args []string) error { return gogit.WorktreeRemove(args[0], force) }),
+	}
+	remove.Flags().CountVarP(&force, "force", "f", "Remove even with local changes; twice to remove a locked worktree")
+
+	var pruneOpts gogit.WorktreePruneOptions
+	prune := &cobra.Command{
+		Use:   "prune [-n] [-v]",
+		Short: "Forget the worktrees whose directory is gone",
+		Args:  cobra.NoArgs,
+		Run:   run(func([]string) error { return gogit.WorktreePrune(pruneOpts) }),
+	}
+	prune.Flags().BoolVarP(&pruneOpts.DryRun, "dry-run", "n", false, "Only report what would be removed")
+	prune.Flags().BoolVarP(&pruneOpts.Verbose, "verbose", "v", false, "Report what is removed")
+
+	var reason string
+	lock := &cobra.Command{
+		Use:   "lock [--reason <string>] <worktree>",
+		Short: "Prevent a worktree from being pruned or removed",
+		Args:  cobra.ExactArgs(1),
+		Run:   run(func(args []string) error { return gogit.WorktreeLock(args[0], reason) }),
+	}
+	lock.Flags().StringVar(&reason, "reason", "", "Reason for the lock")
+
+	unlock := &cobra.Command{
+		Use:   "unlock <worktree>",
+		Short: "Allow a worktree to be pruned or removed again",
+		Args:  cobra.ExactArgs(1),
+		Run:   run(func(args []string) error { return gogit.WorktreeUnlock(args[0]) }),
+	}
+
+	cmd.AddCommand(add, list, remove, prune, lock, unlock)
+	return cmd
+}
@@ -7,160 +7,214 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 )
 
+// AddOptions controls which changes Add stages.
+type AddOptions struct {
+	// All also stages deletions of tracked files that are missing (`add -A`).
+	All bool
+	// Update only stages changes to files already in the index, including
+	// deletions, and never adds new files (`add -u`).
+	Update bool
+	// IntentToAdd records untracked files in the index without their content
+	// (`add -N`), so that they show up in status and diff.
+	IntentToAdd bool
+}
+
 // Add stages files into the index (equivalent to `git add`).
-// It walks the given path, respects .gogitignore, computes SHA-1 hashes,
-// writes new blob objects when needed, and updates the index only for changed files.
-func Add(path string) error {
-	// Load ignore rules
-	ignorePatterns, err := readGogitignore()
+// It walks the paths selected by the pathspecs, respects the ignore rules, computes
+// SHA-1 hashes and writes new blob objects in parallel, and updates the index
+// only for changed files.
+func Add(pathspecs []string, opts AddOptions) error {
+	spec, err := ParsePathspec(pathspecs)
 	if err != nil {
-		return fmt.Errorf("reading .gogitignore: %w", err)
+		return err
 	}
 
 	// Load current index into memory
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
 	indexEntries, err := ReadIndex()
 	if err != nil {
 		return fmt.Errorf("reading index: %w", err)
 	}
 
-	// Channels
-	pathsChan := make(chan string, 100)       // Files discovered by walker
-	resultsChan := make(chan FileResult, 100) // Results from workers
+	// Load ignore rules; files already tracked are never ignored.
+	filter, err := newWorktreeFilter(indexEntries)
+	if err != nil {
+		return err
+	}
+
+	if opts.IntentToAdd {
+		if err := addIntentToAdd(indexEntries, filter, spec); err != nil {
+			return err
+		}
+		return WriteIndex(indexLock, indexEntries)
+	}
 
-	// Worker pool
-	var wgWorkers sync.WaitGroup
-	numWorkers := 4 // Adjust based on CPU cores / typical workload
-	for range numWorkers {
-		wgWorkers.Add(1)
-		go worker(pathsChan, resultsChan, &wgWorkers)
+	// Files that would not be staged are not even read, so that no blob is
+	// written for them: -u never starts tracking new files, and the working
+	// tree file of a skip-worktree or assume-unchanged entry is left alone.
+	stageable := func(path string) bool {
+		entry, exists := indexEntries[path]
+		if !exists {
+			return !opts.Update
+		}
+		return !entry.ignoresWorktree()
 	}
 
-	// Single collector goroutine — the ONLY one that writes to indexEntries
-	var wgCollector sync.WaitGroup
-	wgCollector.Add(1)
-	go func() {
-		defer wgCollector.Done()
-		for result := range resultsChan {
-			if result.Err != nil {
-				log.Printf("Failed to stage %s: %v", result.Path, result.Err)
+	// The walker feeds the shared scanner, which hashes the files and
+	// writes the blobs in parallel.
+	pathsChan := make(chan string, 100)
+	var walkErr error
+	if workdirMap, monitored, err := monitoredWorkdirMap(filter, indexEntries); err != nil {
+		return err
+	} else if monitored {
+		// The filesystem monitor already knows the working tree: only the
+		// files that differ from the index are read again and stored.
+		go func() {
+			defer close(pathsChan)
+			for path, entry := range workdirMap {
+				oldEntry, exists := indexEntries[path]
+				if spec.Match(path) && stageable(path) && (!exists || oldEntry.Mode != entry.Mode || oldEntry.Hash != entry.Hash) {
+					pathsChan <- path
+				}
+			}
+		}()
+	} else {
+		go func() {
+			defer close(pathsChan)
+			walkErr = discoverFiles(pathsChan, filter, spec, stageable)
+		}()
+	}
+
+	scanned, failed, err := scanFiles(pathsChan, true)
+	if err != nil {
+		return err
+	}
+	if walkErr != nil {
+		return fmt.Errorf("error during the directory walk: %w", walkErr)
+	}
+	for _, path := range sortedFailures(failed) {
+		log.Printf("Failed to stage %s: %v", path, failed[path])
+	}
+
+	for path, scannedEntry := range scanned {
+		// Only update index if hash or mode changed
+		entry := IndexEntry{Mode: scannedEntry.Mode, Hash: scannedEntry.Hash}
+		if oldEntry, exists := indexEntries[path]; !exists || oldEntry != entry {
+			indexEntries[path] = entry
+		}
+	}
+
+	// With -A or -u, tracked files that disappeared from the working tree are
+	// removed from the index as well.
+	if opts.All || opts.Update {
+		for indexPath, entry := range indexEntries {
+			// Paths outside a sparse checkout are missing on purpose.
+			if entry.ignoresWorktree() || !spec.Match(indexPath) {
 				continue
 			}
-			// Only update index if hash changed
-			if oldHash, exists := indexEntries[result.Path]; !exists || oldHash != result.Hash {
-				indexEntries[result.Path] = result.Hash
+			if _, err := os.Lstat(indexPath); os.IsNotExist(err) {
+				delete(indexEntries, indexPath)
 			}
 		}
-	}()
-
-	// Start directory walker (closes pathsChan when done)
-	go func() {
-		_ = discoverFiles(pathsChan, ignorePatterns, path)
-	}()
+	}
 
-	// Wait for workers to finish processing all paths
-	wgWorkers.Wait()
-	close(resultsChan) // No more results → collector exits
-	wgCollector.Wait() // Wait for collector to finish updating the map
+	if err := spec.CheckUnmatched(); err != nil {
+		return err
+	}
 
 	// Persist updated index to disk
-	if err := WriteIndex(indexEntries); err != nil {
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
 		return fmt.Errorf("writing index: %w", err)
 	}
 
 	return nil
 }
 
-// FileResult is the structure sent from workers to the collector
-type FileResult struct {
-	Path string
-	Hash string
-	Err  error
-}
+// addIntentToAdd records every untracked file selected by the pathspec as an
+// intent-to-add entry. Files already in the index are left alone.
+func addIntentToAdd(indexEntries map[string]IndexEntry, filter *worktreeFilter, spec *Pathspec) error {
+	// The entries point at the empty blob, which must therefore exist.
+	if _, err := writeBlob(nil); err != nil {
+		return err
+	}
 
-// worker reads files, computes hashes, writes blob objects if needed.
-// It never touches the shared index map directly.
-func worker(pathsChan <-chan string, resultsChan chan<- FileResult, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for filePath := range pathsChan {
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			resultsChan <- FileResult{Path: filePath, Err: fmt.Errorf("read: %w", err)}
-			continue
-		}
+	pathsChan := make(chan string, 100)
+	var walkErr error
+	go func() {
+		defer close(pathsChan)
+		walkErr = discoverFiles(pathsChan, filter, spec, func(path string) bool {
+			_, exists := indexEntries[path]
+			return !exists
+		})
+	}()
 
-		blobHash, buffer, err := HashObject(content)
+	for path := range pathsChan {
+		info, err := os.Lstat(path)
 		if err != nil {
-			resultsChan <- FileResult{Path: filePath, Err: fmt.Errorf("hash: %w", err)}
-			continue
-		}
-
-		// Ensure the blob object exists in .gogit/objects/
-		firstTwo := blobHash[:2]
-		rest := blobHash[2:]
-		objectPath := filepath.Join(ObjectsPath, firstTwo, rest)
-
-		if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-			objDir := filepath.Join(ObjectsPath, firstTwo)
-			if err := os.MkdirAll(objDir, 0755); err != nil {
-				resultsChan <- FileResult{Path: filePath, Err: fmt.Errorf("mkdir: %w", err)}
-				continue
-			}
-			if err := os.WriteFile(objectPath, buffer.Bytes(), 0644); err != nil {
-				resultsChan <- FileResult{Path: filePath, Err: fmt.Errorf("write object: %w", err)}
-				continue
-			}
-		} else if err != nil {
-			resultsChan <- FileResult{Path: filePath, Err: fmt.Errorf("stat object: %w", err)}
+			log.Printf("Failed to stage %s: %v", path, err)
 			continue
 		}
-
-		// Success — send result
-		resultsChan <- FileResult{
-			Path: filePath,
-			Hash: blobHash,
-			Err:  nil,
-		}
+		indexEntries[path] = IndexEntry{Mode: fileMode(info), Hash: EmptyBlobHash, IntentToAdd: true}
+	}
+	if walkErr != nil {
+		return fmt.Errorf("error during the directory walk: %w", walkErr)
 	}
-}
 
-// discoverFiles walks the directory tree and sends regular file paths to pathsChan.
-// It respects .gogitignore and never stages anything inside .gogit.
-func discoverFiles(pathsChan chan<- string, ignorePatterns []string, rootPath string) error {
-	defer close(pathsChan)
+	return spec.CheckUnmatched()
+}
 
-	return filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			log.Printf("Access error %s: %v", path, err)
-			return nil // Continue walking
-		}
+// discoverFiles walks the directories selected by the pathspec and sends the
+// matching regular file paths that keep accepts to pathsChan, which the
+// caller closes.
+// It respects the ignore rules and never stages anything inside .gogit.
+func discoverFiles(pathsChan chan<- string, filter *worktreeFilter, spec *Pathspec, keep func(path string) bool) error {
+	for _, root := range spec.WalkRoots() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// A pathspec naming a deleted file is not an error (see add -A).
+				if path != root || !os.IsNotExist(err) {
+					log.Printf("Access error %s: %v", path, err)
+				}
+				return nil // Continue walking
+			}
 
-		// Always skip the .gogit directory completely
-		if d.IsDir() && (d.Name() == ".gogit" || d.Name() == ".git") {
-			return filepath.SkipDir
-		}
+			// Skip the .gogit directory and apply the ignore rules
+			if skip, err := filter.skip(path, d.IsDir()); err != nil {
+				return err
+			} else if skip {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
-		// Apply .gogitignore rules
-		if ignored, _ := isIgnored(path, ignorePatterns); ignored {
+			// Only stage regular files
 			if d.IsDir() {
-				return filepath.SkipDir
+				return nil
 			}
-			return nil
-		}
 
-		// Only stage regular files
-		if d.IsDir() {
-			return nil
-		}
+			// Extra safety: never stage files inside .gogit
+			path = filepath.ToSlash(filepath.Clean(path))
+			if strings.HasPrefix(path, ".gogit/") {
+				return nil
+			}
 
-		// Extra safety: never stage files inside .gogit
-		if strings.HasPrefix(filepath.ToSlash(path), ".gogit/") {
+			if spec.Match(path) && keep(path) {
+				pathsChan <- path
+			}
 			return nil
+		})
+		if err != nil {
+			return err
 		}
+	}
 
-		pathsChan <- path
-		return nil
-	})
+	return nil
 }
@@ -0,0 +1,383 @@
+package gogit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errQuitPatch is returned by the hunk loop when the user answers "q".
+var errQuitPatch = errors.New("quit")
+
+const addPatchHelp = `y - stage this hunk
+n - do not stage this hunk
+q - quit; do not stage this hunk or any of the remaining ones
+a - stage this hunk and all later hunks in the file
+d - do not stage this hunk or any of the later hunks in the file
+s - split the current hunk into smaller hunks
+e - manually edit the current hunk
+? - print help
+`
+
+// AddPatch interactively stages parts of the changes between the index and
+// the working tree (equivalent to `git add -p`). For every modified tracked
+// file selected by the pathspecs, each hunk is shown on out and the answer is
+// read from in, so the whole session can be scripted. Only the accepted hunks
+// end up in the blob written to the index.
+func AddPatch(pathspecs []string, in io.Reader, out io.Writer) error {
+	spec, err := ParsePathspec(pathspecs)
+	if err != nil {
+		return err
+	}
+
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	var paths []string
+	for path, entry := range indexEntries {
+		if spec.Match(path) && !entry.ignoresWorktree() {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	colors, err := useColors(out)
+	if err != nil {
+		return err
+	}
+	// Each path is looked at once, so the entries staged along the way do
+	// not need to be reflected in the tree.
+	indexTree := IndexToTree(indexEntries)
+	session := &patchSession{in: bufio.NewReader(in), out: out, colors: colors}
+	changed := false
+	for _, path := range paths {
+		oldSide, err := treeDiffSide(indexTree, path)
+		if err != nil {
+			return err
+		}
+		newSide, err := worktreeDiffSide(path)
+		if err != nil {
+			return err
+		}
+		if sameDiffSide(oldSide, newSide) {
+			continue
+		}
+
+		entry, staged, err := session.patchFile(path, oldSide, newSide)
+		if staged {
+			changed = true
+			if entry == nil {
+				delete(indexEntries, path)
+			} else {
+				indexEntries[path] = *entry
+			}
+		}
+		if errors.Is(err, errQuitPatch) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !changed {
+		if len(paths) == 0 {
+			return spec.CheckUnmatched()
+		}
+		fmt.Fprintln(out, "No changes.")
+		return nil
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	return nil
+}
+
+// patchSession holds the prompt input and output of an AddPatch run.
+type patchSession struct {
+	in     *bufio.Reader
+	out    io.Writer
+	colors bool
+}
+
+// ask prints a prompt and returns the first character of the answer.
+// End of input counts as "q".
+func (s *patchSession) ask(prompt string) byte {
+	if s.colors {
+		prompt = colorize(prompt, ColorBlue)
+	}
+	fmt.Fprintf(s.out, "%s ", prompt)
+	answer, err := s.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		if err != nil {
+			fmt.Fprintln(s.out)
+			return 'q'
+		}
+		return 0
+	}
+	return answer[0]
+}
+
+// patchFile runs the prompts for one file. It returns the new index entry
+// (nil to remove the file from the index) and whether anything was staged.
+func (s *patchSession) patchFile(path string, oldSide, newSide diffSide) (*IndexEntry, bool, error) {
+	fmt.Fprintf(s.out, "diff --gogit a/%s b/%s\n", path, path)
+
+	// Deleted files and binary files are staged as a whole.
+	if newSide.entry == nil {
+		return s.patchWhole("Stage deletion", nil)
+	}
+	if isBinary(oldSide.content) || isBinary(newSide.content) {
+		fmt.Fprintln(s.out, "Binary files differ")
+		entry, staged, err := s.patchWhole("Stage this binary file", &IndexEntry{Mode: newSide.entry.Mode, Hash: newSide.entry.Hash})
+		if staged {
+			// The working tree side is only hashed; store it now that it
+			// is staged.
+			if _, err := writeBlob(newSide.content); err != nil {
+				return nil, false, err
+			}
+		}
+		return entry, staged, err
+	}
+
+	// Intent-to-add files have no staged content yet: their whole content
+	// is offered as added lines.
+	if oldSide.entry == nil {
+		fmt.Fprintf(s.out, "new file mode %s\n", newSide.entry.Mode)
+		oldSide.entry = &TreeEntry{Mode: newSide.entry.Mode}
+	}
+
+	mode := oldSide.entry.Mode
+	staged := false
+	if oldSide.entry.Mode != newSide.entry.Mode {
+		fmt.Fprintf(s.out, "old mode %s\nnew mode %s\n", oldSide.entry.Mode, newSide.entry.Mode)
+		switch s.askWhole("Stage mode change") {
+		case 'y':
+			mode, staged = newSide.entry.Mode, true
+		case 'q':
+			return nil, false, errQuitPatch
+		}
+	}
+
+	script := diffLines(splitLines(oldSide.content), splitLines(newSide.content))
+	accepted := make([]bool, len(script))
+	hunks := makeHunks(script, diffContextLines)
+	fmt.Fprintf(s.out, "--- a/%s\n+++ b/%s\n", path, path)
+
+	var quit error
+	for i := 0; i < len(hunks); i++ {
+		writeHunk(s.out, script, hunks[i], s.colors)
+
+		prompt := fmt.Sprintf("(%d/%d) Stage this hunk [y,n,q,a,d,e,?]?", i+1, len(hunks))
+		canSplit := len(splitHunk(script, hunks[i])) > 1
+		if canSplit {
+			prompt = fmt.Sprintf("(%d/%d) Stage this hunk [y,n,q,a,d,s,e,?]?", i+1, len(hunks))
+		}
+
+		switch s.ask(prompt) {
+		case 'y':
+			acceptHunk(script, accepted, hunks[i])
+		case 'n':
+		case 'a':
+			for _, h := range hunks[i:] {
+				acceptHunk(script, accepted, h)
+			}
+			i = len(hunks)
+		case 'd':
+			i = len(hunks)
+		case 'q':
+			quit = errQuitPatch
+			i = len(hunks)
+		case 's':
+			if !canSplit {
+				fmt.Fprintln(s.out, "Sorry, cannot split this hunk")
+				i--
+				continue
+			}
+			pieces := splitHunk(script, hunks[i])
+			fmt.Fprintf(s.out, "Split into %d hunks.\n", len(pieces))
+			hunks = append(hunks[:i], append(pieces, hunks[i+1:]...)...)
+			i--
+		case 'e':
+			edited, err := editHunk(script, hunks[i])
+			if err != nil {
+				fmt.Fprintf(s.out, "%v\n", err)
+				i--
+				continue
+			}
+			script, accepted, hunks = spliceHunk(script, accepted, hunks, i, edited)
+		default:
+			fmt.Fprint(s.out, addPatchHelp)
+			i--
+		}
+	}
+
+	for _, ok := range accepted {
+		staged = staged || ok
+	}
+	if !staged {
+		return nil, false, quit
+	}
+
+	content := applySelected(script, accepted)
+	blobHash, err := writeBlob(content)
+	if err != nil {
+		return nil, false, err
+	}
+	return &IndexEntry{Mode: mode, Hash: blobHash}, true, quit
+}
+
+// patchWhole asks whether to stage an all-or-nothing change.
+func (s *patchSession) patchWhole(prompt string, entry *IndexEntry) (*IndexEntry, bool, error) {
+	switch s.askWhole(prompt) {
+	case 'y':
+		return entry, true, nil
+	case 'q':
+		return nil, false, errQuitPatch
+	}
+	return nil, false, nil
+}
+
+func (s *patchSession) askWhole(prompt string) byte {
+	for {
+		answer := s.ask(prompt + " [y,n,q,?]?")
+		if answer == 'y' || answer == 'n' || answer == 'q' {
+			return answer
+		}
+		fmt.Fprint(s.out, "y - stage this change\nn - do not stage this change\nq - quit\n")
+	}
+}
+
+func acceptHunk(script []diffLine, accepted []bool, h hunk) {
+	for i := h.start; i < h.end; i++ {
+		if script[i].op != opEqual {
+			accepted[i] = true
+		}
+	}
+}
+
+// spliceHunk replaces the lines of hunks[i] with the edited ones, which are
+// all accepted, and shifts the following hunks accordingly.
+func spliceHunk(script []diffLine, accepted []bool, hunks []hunk, i int, edited []diffLine) ([]diffLine, []bool, []hunk) {
+	h := hunks[i]
+
+	newScript := append(append(append([]diffLine(nil), script[:h.start]...), edited...), script[h.end:]...)
+	newAccepted := append(append([]bool(nil), accepted[:h.start]...), make([]bool, len(edited))...)
+	newAccepted = append(newAccepted, accepted[h.end:]...)
+	for j := range edited {
+		newAccepted[h.start+j] = edited[j].op != opEqual
+	}
+
+	delta := len(edited) - (h.end - h.start)
+	hunks[i] = hunk{start: h.start, end: h.start + len(edited)}
+	for j := i + 1; j < len(hunks); j++ {
+		hunks[j].start += delta
+		hunks[j].end += delta
+	}
+	return newScript, newAccepted, hunks
+}
+
+// editHunk opens the hunk in the user's editor and parses the result. The
+// old side of the edited hunk (context and removed lines) must be unchanged.
+func editHunk(script []diffLine, h hunk) ([]diffLine, error) {
+	editPath := filepath.Join(RepoPath, "ADD_EDIT.patch")
+	defer os.Remove(editPath)
+
+	var buffer strings.Builder
+	buffer.WriteString("# Manual hunk edit mode -- see bottom for a quick guide.\n")
+	buffer.WriteString(hunkHeader(script, h) + "\n")
+	for _, line := range script[h.start:h.end] {
+		buffer.WriteString(string(line.op) + strings.TrimSuffix(line.text, "\n") + "\n")
+		if !strings.HasSuffix(line.text, "\n") {
+			buffer.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	buffer.WriteString(`# ---
+# To remove '-' lines, make them ' ' lines (context).
+# To remove '+' lines, delete them.
+# Lines starting with # will be removed.
+`)
+	if err := os.WriteFile(editPath, []byte(buffer.String()), 0644); err != nil {
+		return nil, fmt.Errorf("error writing %s: %w", editPath, err)
+	}
+
+	if err := runEditor(editPath); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(editPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", editPath, err)
+	}
+
+	var edited []diffLine
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@@"):
+		case strings.HasPrefix(line, "\\"):
+			if len(edited) > 0 {
+				edited[len(edited)-1].text = strings.TrimSuffix(edited[len(edited)-1].text, "\n")
+			}
+		case line == "\n":
+			edited = append(edited, diffLine{opEqual, "\n"})
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			text := line[1:]
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			edited = append(edited, diffLine{diffOp(line[0]), text})
+		default:
+			return nil, fmt.Errorf("error: your edited hunk does not apply (bad line %q)", strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	if oldSideOf(edited) != oldSideOf(script[h.start:h.end]) {
+		return nil, fmt.Errorf("error: your edited hunk does not apply")
+	}
+	return edited, nil
+}
+
+// oldSideOf returns the content of the context and removed lines.
+func oldSideOf(lines []diffLine) string {
+	var builder strings.Builder
+	for _, line := range lines {
+		if line.op != opInsert {
+			builder.WriteString(line.text)
+		}
+	}
+	return builder.String()
+}
+
+// runEditor opens path in $GOGIT_EDITOR, $VISUAL or $EDITOR (vi by default).
+func runEditor(path string) error {
+	editor := "vi"
+	for _, name := range []string{"GOGIT_EDITOR", "VISUAL", "EDITOR"} {
+		if value := os.Getenv(name); value != "" {
+			editor = value
+			break
+		}
+	}
+
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error: there was a problem with the editor '%s': %w", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// attributesFileName is the name of the per-directory attribute files.
+const attributesFileName = ".gogitattributes"
+
+// The states of an attribute other than a value.
+const (
+	// AttrSet is the state of "attr".
+	AttrSet = "set"
+	// AttrUnset is the state of "-attr".
+	AttrUnset = "unset"
+	// AttrUnspecified is the state of an attribute no line gives, or one
+	// reset with "!attr".
+	AttrUnspecified = "unspecified"
+)
+
+// attrNamePattern is the syntax of attribute names.
+var attrNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.][-A-Za-z0-9_.]*$`)
+
+// ValidAttributeName reports whether name can be used as an attribute.
+func ValidAttributeName(name string) bool {
+	return attrNamePattern.MatchString(name)
+}
+
+// attrAssignment gives an attribute a state: AttrSet, AttrUnset,
+// AttrUnspecified or a value.
+type attrAssignment struct {
+	name  string
+	value string
+}
+
+// attrRule is one line of an attribute file.
+type attrRule struct {
+	match       ignoreRule // the pattern, with the same syntax as ignore files
+	assignments []attrAssignment
+}
+
+// builtinMacros are the macros defined before any file is read.
+var builtinMacros = map[string][]attrAssignment{
+	"binary": {{"diff", AttrUnset}, {"merge", AttrUnset}, {"text", AttrUnset}},
+}
+
+// AttributeMatcher resolves the attributes of paths, following the rules of
+// gitattributes. Lines are read from, in increasing order of precedence:
+//
+//   - the file named by core.attributesFile (by default
+//     ~/.config/gogit/attributes)
+//   - the .gogitattributes file of every directory, deeper files winning
+//     over the ones of their parents
+//   - .gogit/info/attributes
+//
+// Each line is a pattern followed by attributes: "attr" sets one, "-attr"
+// unsets it, "attr=value" gives it a value and "!attr" makes it unspecified
+// again. For every attribute the last matching line of the file with the
+// highest precedence wins. "[attr]name ..." defines a macro, which sets the
+// attributes it lists wherever it is set itself; "binary" is predefined as
+// "-diff -merge -text". Macros may only be defined outside of
+// subdirectories.
+//
+// Like IgnoreMatcher, the per-directory files are read on demand and a
+// matcher is safe for concurrent use.
+type AttributeMatcher struct {
+	global []attrRule
+	info   []attrRule
+	macros map[string][]attrAssignment
+
+	mu     sync.Mutex
+	perDir map[string][]attrRule // directory -> rules of its .gogitattributes
+}
+
+// NewAttributeMatcher loads the global and repository-wide attribute files,
+// and the macros they and the root .gogitattributes define.
+func NewAttributeMatcher() (*AttributeMatcher, error) {
+	m := &AttributeMatcher{
+		macros: make(map[string][]attrAssignment),
+		perDir: make(map[string][]attrRule),
+	}
+	for name, assignments := range builtinMacros {
+		m.macros[name] = assignments
+	}
+
+	globalPath, err := userConfigFile("core.attributesFile", "attributes")
+	if err != nil {
+		return nil, err
+	}
+	if globalPath != "" {
+		if m.global, err = m.readAttributesFile(globalPath, globalPath, ""); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := m.dirRules(""); err != nil {
+		return nil, err
+	}
+	infoSource := filepath.ToSlash(filepath.Join(ROOT, "info", "attributes"))
+	if m.info, err = m.readAttributesFile(AttributesPath, infoSource, ""); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// readAttributesFile parses an attribute file, recording the macros it
+// defines. A missing file has no rules.
+func (m *AttributeMatcher) readAttributesFile(filePath, source, base string) ([]attrRule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", source, err)
+	}
+	defer file.Close()
+
+	var rules []attrRule
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		where := fmt.Sprintf("%s:%d", source, lineNumber)
+		pattern, assignments, ok := parseAttributesLine(scanner.Text(), where)
+		if !ok {
+			continue
+		}
+
+		if macro, isMacro := strings.CutPrefix(pattern, "[attr]"); isMacro {
+			switch {
+			case base != "":
+				fmt.Fprintf(os.Stderr, "warning: %s not allowed: %s\n", pattern, where)
+			case !ValidAttributeName(macro):
+				fmt.Fprintf(os.Stderr, "warning: %s: not a valid attribute name: %s\n", macro, where)
+			default:
+				m.macros[macro] = assignments
+			}
+			continue
+		}
+
+		match, ok := parseIgnoreLine(pattern)
+		if !ok {
+			continue
+		}
+		if match.negated {
+			fmt.Fprintf(os.Stderr, "warning: negative patterns are ignored in attribute files: %s\n", where)
+			continue
+		}
+		match.base = base
+		match.source = source
+		match.line = lineNumber
+		rules = append(rules, attrRule{match: match, assignments: assignments})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", source, err)
+	}
+	return rules, nil
+}
+
+// parseAttributesLine splits a line into its pattern, which may be quoted,
+// and its attributes. It returns false for blank lines and comments.
+func parseAttributesLine(line, where string) (string, []attrAssignment, bool) {
+	line = strings.TrimLeft(strings.TrimSuffix(line, "\r"), " \t")
+	if line == "" || line[0] == '#' {
+		return "", nil, false
+	}
+
+	var pattern, rest string
+	if line[0] == '"' {
+		quoted, err := strconv.QuotedPrefix(line)
+		if err == nil {
+			pattern, err = strconv.Unquote(quoted)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: bad quoted pattern: %s\n", where)
+			return "", nil, false
+		}
+		rest = line[len(quoted):]
+	} else {
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		pattern, rest = line[:end], line[end:]
+	}
+
+	var assignments []attrAssignment
+	for _, field := range strings.Fields(rest) {
+		var a attrAssignment
+		switch {
+		case strings.HasPrefix(field, "-"):
+			a = attrAssignment{field[1:], AttrUnset}
+		case strings.HasPrefix(field, "!"):
+			a = attrAssignment{field[1:], AttrUnspecified}
+		default:
+			name, value, hasValue := strings.Cut(field, "=")
+			if !hasValue {
+				value = AttrSet
+			}
+			a = attrAssignment{name, value}
+		}
+		if !ValidAttributeName(a.name) {
+			fmt.Fprintf(os.Stderr, "warning: %s: not a valid attribute name: %s\n", a.name, where)
+			continue
+		}
+		assignments = append(assignments, a)
+	}
+	return pattern, assignments, true
+}
+
+// dirRules returns the rules of the .gogitattributes file in dir ("" for
+// the root), reading it the first time.
+func (m *AttributeMatcher) dirRules(dir string) ([]attrRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rules, loaded := m.perDir[dir]; loaded {
+		return rules, nil
+	}
+
+	source := path.Join(dir, attributesFileName)
+	rules, err := m.readAttributesFile(filepath.FromSlash(source), source, dir)
+	if err != nil {
+		return nil, err
+	}
+	m.perDir[dir] = rules
+	return rules, nil
+}
+
+// Attributes returns the state of every attribute specified for filePath
+// (relative to the repository root): AttrSet, AttrUnset or a value.
+// Attributes missing from the map are unspecified.
+func (m *AttributeMatcher) Attributes(filePath string) (map[string]string, error) {
+	filePath = strings.Trim(filepath.ToSlash(filePath), "/")
+	state := make(map[string]string)
+	apply := func(rules []attrRule) {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].match.matches(filePath, false) {
+				m.assign(state, rules[i].assignments)
+			}
+		}
+	}
+
+	// From the highest precedence down, so that the first state found for
+	// an attribute is the one that wins.
+	apply(m.info)
+	dir := path.Dir(filePath)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		rules, err := m.dirRules(dir)
+		if err != nil {
+			return nil, err
+		}
+		apply(rules)
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	apply(m.global)
+
+	for name, value := range state {
+		if value == AttrUnspecified {
+			delete(state, name)
+		}
+	}
+	return state, nil
+}
+
+// assign records the assignments of a line, last first, for the attributes
+// not decided yet, expanding the macros that are set.
+func (m *AttributeMatcher) assign(state map[string]string, assignments []attrAssignment) {
+	for i := len(assignments) - 1; i >= 0; i-- {
+		a := assignments[i]
+		if _, decided := state[a.name]; decided {
+			continue
+		}
+		state[a.name] = a.value
+		if expansion, isMacro := m.macros[a.name]; isMacro && a.value == AttrSet {
+			m.assign(state, expansion)
+		}
+	}
+}
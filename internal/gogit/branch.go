@@ -6,16 +6,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 func ListBranches() error {
 	branches := []string{}
-	headRef, err := GetHeadRef()
+	head, err := ReadHead()
 	if err != nil {
 		return err
 	}
-	currentBranch := strings.TrimPrefix(headRef["ref:"], "refs/heads/")
 	err = filepath.WalkDir(RefHeadsPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Printf("Access error %s: %v", path, err)
@@ -39,7 +37,11 @@ func ListBranches() error {
 		return fmt.Errorf("error listing branches: %w", err)
 	}
 
-	PrintBranches(branches, currentBranch)
+	if head.Detached() {
+		fmt.Printf("*%s (HEAD detached at %s)%s\n", ColorGreen, ShortHash(head.Hash), ColorReset)
+	}
+
+	PrintBranches(branches, head.Branch)
 	return nil
 }
 
@@ -59,18 +61,13 @@ func CreateBranch(branchName string) error {
 	}
 
 	branchRefPath := filepath.Join(RefHeadsPath, branchName)
-	if err := os.MkdirAll(filepath.Dir(branchRefPath), 0755); err != nil {
-		return fmt.Errorf("error creating branch directories: %w", err)
-	}
-
-	branchRefFile, err := os.Create(branchRefPath)
+	refLock, err := AcquireLock(branchRefPath)
 	if err != nil {
 		return fmt.Errorf("error creating branch ref file: %w", err)
 	}
-	defer branchRefFile.Close()
+	defer refLock.Rollback()
 
-	_, err = branchRefFile.WriteString(currentHash + "\n")
-	if err != nil {
+	if err := refLock.Commit([]byte(currentHash + "\n")); err != nil {
 		return fmt.Errorf("error writing to branch ref file: %w", err)
 	}
 
@@ -80,20 +77,33 @@ func CreateBranch(branchName string) error {
 }
 
 func DeleteBranch(name string) error {
-	headRef, err := GetHeadRef()
+	wt, err := branchWorktree(name)
 	if err != nil {
 		return err
 	}
-	currentBranch := strings.TrimPrefix(headRef["ref:"], "refs/heads/")
-	if name == currentBranch {
-		return fmt.Errorf("error: cannot delete branch '%s' used by worktree at '%s'", name, RepoPath)
+	if wt != nil {
+		return fmt.Errorf("error: cannot delete branch '%s' used by worktree at '%s'", name, wt.path)
+	}
+
+	branchRefPath := filepath.Join(RefHeadsPath, name)
+	refLock, err := AcquireLock(branchRefPath)
+	if err != nil {
+		return err
 	}
+	defer refLock.Rollback()
 
-	err = os.Remove(filepath.Join(RefHeadsPath, name))
+	err = os.Remove(branchRefPath)
 	if err != nil {
 		return fmt.Errorf("error: branch '%s' not found", name)
 	}
 
+	// Forget the upstream of the deleted branch as well.
+	if upstream, err := BranchUpstream(name); err == nil && upstream != nil {
+		if err := UnsetUpstream(name); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("branch '%s' deleted successfully\n", name)
 
 	return nil
@@ -0,0 +1,98 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CatFileMode selects what cat-file prints about an object.
+type CatFileMode int
+
+const (
+	// CatFilePretty prints the content of the object (`-p`).
+	CatFilePretty CatFileMode = iota
+	// CatFileType prints "blob", "tree" or "commit" (`-t`).
+	CatFileType
+	// CatFileSize prints the size of the content in bytes (`-s`).
+	CatFileSize
+	// CatFileExists prints nothing; only the result tells whether the object
+	// exists (`-e`).
+	CatFileExists
+)
+
+// CatFile prints an object of the repository, named by a revision, a full
+// or abbreviated hash, or "<rev>:<path>" for a file of a commit. It works in
+// bare repositories. It returns false when the object does not exist, which
+// is only reported as an error unless mode is CatFileExists.
+func CatFile(object string, mode CatFileMode) (bool, error) {
+	hash, err := resolveObject(object)
+	if err != nil {
+		return false, err
+	}
+	if hash == "" {
+		if mode == CatFileExists {
+			return false, nil
+		}
+		return false, fmt.Errorf("fatal: Not a valid object name %s", object)
+	}
+
+	objectType, content, err := readRawObject(hash)
+	if err != nil {
+		return false, err
+	}
+
+	switch mode {
+	case CatFileType:
+		fmt.Println(objectType)
+	case CatFileSize:
+		fmt.Println(len(content))
+	case CatFilePretty:
+		os.Stdout.Write(content)
+	}
+	return true, nil
+}
+
+// resolveObject returns the hash of an object name, or "" when nothing
+// matches.
+func resolveObject(object string) (string, error) {
+	if rev, filePath, isPath := strings.Cut(object, ":"); isPath {
+		if rev == "" {
+			rev = HEAD
+		}
+		tree, err := ReadCommitTree(rev)
+		if err != nil {
+			return "", err
+		}
+		entry, found := tree[strings.TrimPrefix(filePath, "./")]
+		if !found {
+			return "", fmt.Errorf("fatal: path '%s' does not exist in '%s'", filePath, rev)
+		}
+		return entry.Hash, nil
+	}
+
+	if strings.ContainsAny(object, "~^") {
+		return ResolveRevision(object)
+	}
+	return resolveRevisionBase(object)
+}
+
+// readRawObject reads an object and tells its type. Blobs are stored with a
+// "blob <size>" header; commits and trees are stored as their bare content,
+// a commit starting with its "tree" line.
+func readRawObject(hash string) (string, []byte, error) {
+	content, err := os.ReadFile(filepath.Join(ObjectsPath, hash[:2], hash[2:]))
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+
+	if header, blob, found := bytes.Cut(content, []byte{0}); found && bytes.HasPrefix(header, []byte("blob ")) {
+		return "blob", blob, nil
+	}
+	if bytes.HasPrefix(content, []byte("tree ")) {
+		return "commit", content, nil
+	}
+	return "tree", content, nil
+}
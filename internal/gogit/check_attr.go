@@ -0,0 +1,84 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CheckAttrOptions controls the output of CheckAttr.
+type CheckAttrOptions struct {
+	// All lists every attribute specified for each path instead of the
+	// ones asked for.
+	All bool
+	// Stdin reads the paths from standard input, one per line, after the
+	// ones given as arguments.
+	Stdin bool
+}
+
+// CheckAttr prints the state of the attributes of each path, one
+// "<path>: <attribute>: <state>" line per attribute, where the state is
+// "set", "unset", "unspecified" or the value of the attribute. With All
+// only the attributes that are specified are listed, sorted by name.
+func CheckAttr(attrs, paths []string, opts CheckAttrOptions) error {
+	switch {
+	case opts.All && len(attrs) > 0:
+		return fmt.Errorf("fatal: attributes and --all both specified")
+	case !opts.All && len(attrs) == 0:
+		return fmt.Errorf("fatal: no attribute specified")
+	case len(paths) == 0 && !opts.Stdin:
+		return fmt.Errorf("fatal: no path specified")
+	}
+	for _, attr := range attrs {
+		if !ValidAttributeName(attr) {
+			return fmt.Errorf("fatal: %s: not a valid attribute name", attr)
+		}
+	}
+
+	matcher, err := NewAttributeMatcher()
+	if err != nil {
+		return err
+	}
+
+	check := func(arg string) error {
+		return checkAttrPath(matcher, arg, attrs, opts)
+	}
+	for _, arg := range paths {
+		if err := check(arg); err != nil {
+			return err
+		}
+	}
+	if opts.Stdin {
+		return forEachLine(os.Stdin, check)
+	}
+	return nil
+}
+
+// checkAttrPath prints the attributes of a single path.
+func checkAttrPath(matcher *AttributeMatcher, arg string, attrs []string, opts CheckAttrOptions) error {
+	filePath, inside := rootPath(arg)
+	if !inside || filePath == "." {
+		return fmt.Errorf("fatal: %s: '%s' is outside repository", arg, arg)
+	}
+
+	state, err := matcher.Attributes(filePath)
+	if err != nil {
+		return err
+	}
+
+	if opts.All {
+		attrs = make([]string, 0, len(state))
+		for name := range state {
+			attrs = append(attrs, name)
+		}
+		sort.Strings(attrs)
+	}
+	for _, attr := range attrs {
+		value, specified := state[attr]
+		if !specified {
+			value = AttrUnspecified
+		}
+		fmt.Printf("%s: %s: %s\n", arg, attr, value)
+	}
+	return nil
+}
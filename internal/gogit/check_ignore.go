@@ -0,0 +1,115 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CheckIgnoreOptions controls the output of CheckIgnore.
+type CheckIgnoreOptions struct {
+	// Verbose shows the source file, line number and pattern that decided
+	// each path, negations included.
+	Verbose bool
+	// NonMatching also lists the paths no pattern matched (with Verbose).
+	NonMatching bool
+	// Stdin reads the paths from standard input, one per line, after the
+	// ones given as arguments.
+	Stdin bool
+}
+
+// CheckIgnore prints the paths that are ignored and returns whether any was.
+// Tracked files are never ignored, so they are not reported.
+//
+// With Verbose each line has the form "<source>:<line>:<pattern>\t<path>",
+// and paths re-included by a "!" pattern are shown as well; with NonMatching
+// the paths no pattern matched are printed as "::\t<path>".
+func CheckIgnore(paths []string, opts CheckIgnoreOptions) (bool, error) {
+	if opts.NonMatching && !opts.Verbose {
+		return false, fmt.Errorf("fatal: --non-matching is only valid with --verbose")
+	}
+	if len(paths) == 0 && !opts.Stdin {
+		return false, fmt.Errorf("fatal: no path specified")
+	}
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return false, fmt.Errorf("reading index: %w", err)
+	}
+	ignore, err := NewIgnoreMatcher()
+	if err != nil {
+		return false, err
+	}
+
+	anyIgnored := false
+	check := func(arg string) error {
+		ignored, err := checkIgnorePath(ignore, indexEntries, arg, opts)
+		anyIgnored = anyIgnored || ignored
+		return err
+	}
+
+	for _, arg := range paths {
+		if err := check(arg); err != nil {
+			return false, err
+		}
+	}
+	if opts.Stdin {
+		if err := forEachLine(os.Stdin, check); err != nil {
+			return false, err
+		}
+	}
+
+	return anyIgnored, nil
+}
+
+// checkIgnorePath reports a single path and returns whether it is ignored.
+func checkIgnorePath(ignore *IgnoreMatcher, indexEntries map[string]IndexEntry, arg string, opts CheckIgnoreOptions) (bool, error) {
+	filePath, inside := rootPath(arg)
+	if !inside || filePath == "." {
+		return false, fmt.Errorf("fatal: %s: '%s' is outside repository", arg, arg)
+	}
+
+	var rule *ignoreRule
+	if _, tracked := indexEntries[filePath]; !tracked {
+		isDir := strings.HasSuffix(arg, "/")
+		if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+			isDir = true
+		}
+
+		var err error
+		if rule, err = ignore.Match(filePath, isDir); err != nil {
+			return false, err
+		}
+	}
+
+	ignored := rule != nil && !rule.negated
+	switch {
+	case rule != nil && opts.Verbose:
+		fmt.Printf("%s:%d:%s\t%s\n", rule.source, rule.line, rule.text, arg)
+	case ignored:
+		fmt.Println(arg)
+	case rule == nil && opts.NonMatching:
+		fmt.Printf("::\t%s\n", arg)
+	}
+	return ignored, nil
+}
+
+// forEachLine calls fn with every line read from r.
+func forEachLine(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading standard input: %w", err)
+	}
+	return nil
+}
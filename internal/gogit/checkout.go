@@ -22,8 +22,17 @@ func CheckoutBranch(branchName string, createBranch bool) error {
 		return fmt.Errorf("error: branch '%s' does not exist", branchName)
 	}
 
+	// A branch is checked out in one working tree at a time.
+	wt, err := branchWorktree(branchName)
+	if err != nil {
+		return err
+	}
+	if wt != nil && !wt.current {
+		return fmt.Errorf("fatal: '%s' is already used by worktree at '%s'", branchName, wt.path)
+	}
+
 	// Load current branch tree
-	var currentTreeMap map[string]string
+	var currentTreeMap map[string]TreeEntry
 	currentHash, err := GetBranchHash()
 	if err != nil {
 		return err
@@ -42,7 +51,7 @@ func CheckoutBranch(branchName string, createBranch bool) error {
 	}
 
 	// Load target branch tree
-	var targetTreeMap map[string]string
+	var targetTreeMap map[string]TreeEntry
 	targetBranchHash, err := GetTargetBranchHash(branchName)
 	if err != nil {
 		return err
@@ -60,42 +69,77 @@ func CheckoutBranch(branchName string, createBranch bool) error {
 		}
 	}
 
-	// Safety check for uncommitted changes
-	workdirMap, err := BuildWorkdirMap()
+	sparse, err := ReadSparseCheckout()
 	if err != nil {
-		return fmt.Errorf("could not build the working directory map: %w", err)
+		return err
 	}
 
-	ignorePatterns, err := readGogitignore()
+	// The index moves to the target tree as well: staged changes are carried
+	// over, unless the target branch changes the same files.
+	indexLock, err := LockIndex()
 	if err != nil {
-		return fmt.Errorf("error reading .gogitignore: %w", err)
+		return err
 	}
+	defer indexLock.Rollback()
 
-	filteredWorkdirMap := make(map[string]string)
-	for path, hash := range workdirMap {
-		ignored, err := isIgnored(path, ignorePatterns)
-		if err != nil {
-			return fmt.Errorf("error checking ignore patterns for %s: %w", path, err)
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	newIndex := TreeToIndex(targetTreeMap)
+	sparse.markSkipWorktree(newIndex)
+	for path, indexEntry := range indexEntries {
+		currentEntry, inCurrent := currentTreeMap[path]
+		if inCurrent && !indexEntry.IntentToAdd && (TreeEntry{Mode: indexEntry.Mode, Hash: indexEntry.Hash}) == currentEntry {
+			continue // Not staged: follows the target tree.
 		}
-		if !ignored {
-			filteredWorkdirMap[path] = hash
+
+		targetEntry, inTarget := targetTreeMap[path]
+		if inCurrent != inTarget || currentEntry != targetEntry {
+			return fmt.Errorf("error: your local changes to the file '%s' would be overwritten by checkout", path)
+		}
+		newIndex[path] = indexEntry
+	}
+	keepIndexFlags(newIndex, indexEntries)
+	for path := range currentTreeMap {
+		if _, inIndex := indexEntries[path]; inIndex {
+			continue
+		}
+		// A staged deletion is carried over when the target has the same file.
+		if targetEntry, inTarget := targetTreeMap[path]; inTarget {
+			if targetEntry != currentTreeMap[path] {
+				return fmt.Errorf("error: your local changes to the file '%s' would be overwritten by checkout", path)
+			}
+			delete(newIndex, path)
 		}
 	}
 
-	for path, workdirHash := range filteredWorkdirMap {
-		currentHash, inCurrent := currentTreeMap[path]
-		targetHash, inTarget := targetTreeMap[path]
+	// Safety check for uncommitted changes
+	workdirMap, err := BuildWorkdirMap()
+	if err != nil {
+		return fmt.Errorf("could not build the working directory map: %w", err)
+	}
 
-		if inCurrent && (!inTarget || currentHash != targetHash) && workdirHash != currentHash {
+	// Ignored files are already left out of the working tree map.
+	for path, workdirEntry := range workdirMap {
+		currentEntry, inCurrent := currentTreeMap[path]
+		targetEntry, inTarget := targetTreeMap[path]
+
+		if inCurrent && (!inTarget || currentEntry != targetEntry) && workdirEntry != currentEntry {
 			return fmt.Errorf("error: your local changes to the file '%s' would be overwritten by checkout", path)
 		}
 	}
 
 	// The DIFF
-	if err := ApplyDiffCheckout(currentTreeMap, targetTreeMap); err != nil {
+	if err := ApplyDiffCheckout(currentTreeMap, targetTreeMap, sparse); err != nil {
 		return fmt.Errorf("error applying diff: %w", err)
 	}
 
+	if err := WriteIndex(indexLock, newIndex); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+
 	// Update HEAD to point to the new branch
 	if err := UpdateHeadRef(branchName); err != nil {
 		return fmt.Errorf("error updating HEAD ref: %w", err)
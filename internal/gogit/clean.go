@@ -0,0 +1,411 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CleanOptions controls which untracked files Clean removes.
+type CleanOptions struct {
+	// DryRun only lists what would be removed.
+	DryRun bool
+	// Force removes the files; without it (and without Interactive) Clean
+	// behaves as a dry run.
+	Force bool
+	// Directories removes untracked directories as well.
+	Directories bool
+	// NoIgnore removes ignored files too: only the Exclude patterns apply.
+	NoIgnore bool
+	// OnlyIgnored removes only the ignored files.
+	OnlyIgnored bool
+	// Exclude adds ignore patterns to the standard ones.
+	Exclude []string
+	// Interactive lets the user choose what to remove.
+	Interactive bool
+}
+
+const cleanHelp = `Prompt help:
+1          - select a numbered item
+foo        - select item based on unique prefix
+           - (empty) select nothing
+clean               - start cleaning
+filter by pattern   - exclude items from deletion
+select by numbers   - select items to be deleted by numbers
+ask each            - confirm each deletion (like "rm -i")
+quit                - stop cleaning
+help                - this screen
+`
+
+// cleanCommands are the entries of the interactive menu.
+var cleanCommands = []string{"clean", "filter by pattern", "select by numbers", "ask each", "quit", "help"}
+
+// Clean removes the untracked files selected by the pathspecs, or the ones
+// under the current directory without pathspecs (equivalent to `git clean`). Untracked directories are only entered with Directories, and
+// one whose whole content can go is removed as a single item. Nested
+// repositories are never touched.
+//
+// The interactive menu reads its answers from in and writes to out, like
+// AddPatch, so that a session can be scripted.
+func Clean(pathspecs []string, opts CleanOptions, in io.Reader, out io.Writer) error {
+	if opts.NoIgnore && opts.OnlyIgnored {
+		return fmt.Errorf("fatal: -x and -X cannot be used together")
+	}
+
+	spec, err := ParsePathspec(pathspecs)
+	if err != nil {
+		return err
+	}
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	c := &cleaner{opts: opts, spec: spec, tracked: trackedPaths(indexEntries)}
+	if opts.NoIgnore {
+		c.ignore = newPatternMatcher(opts.Exclude)
+	} else {
+		if c.ignore, err = NewIgnoreMatcher(); err != nil {
+			return err
+		}
+		c.ignore.addPatterns(opts.Exclude)
+	}
+
+	// Without pathspecs only the directory the command was run from is
+	// cleaned. Items are shown, and picked interactively, relative to it.
+	start := ""
+	if spec.IsEmpty() {
+		start = prefix
+	}
+	items, _, err := c.collect(start)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		items[i] = displayPath(item)
+	}
+	sort.Strings(items)
+
+	if opts.Interactive && !opts.DryRun {
+		session := &cleanSession{in: bufio.NewReader(in), out: out}
+		if items, err = session.run(items); err != nil {
+			return err
+		}
+	} else if opts.DryRun || !opts.Force {
+		for _, item := range items {
+			fmt.Fprintf(out, "Would remove %s\n", item)
+		}
+		if !opts.DryRun && len(items) > 0 {
+			fmt.Fprintln(out, "hint: nothing was removed; use 'gogit clean -f' to delete these files")
+		}
+		return nil
+	}
+
+	for _, item := range items {
+		fmt.Fprintf(out, "Removing %s\n", item)
+		if err := os.RemoveAll(UserPath(strings.TrimSuffix(item, "/"))); err != nil {
+			return fmt.Errorf("error: could not remove %s: %w", item, err)
+		}
+	}
+	return nil
+}
+
+// cleaner finds the paths Clean removes.
+type cleaner struct {
+	opts    CleanOptions
+	spec    *Pathspec
+	ignore  *IgnoreMatcher
+	tracked map[string]bool // tracked files and their parent directories
+}
+
+// collect returns the removable items below dir ("" for the root), with a
+// trailing "/" for directories, and whether everything in dir can go.
+func (c *cleaner) collect(dir string) ([]string, bool, error) {
+	osDir := "."
+	if dir != "" {
+		osDir = filepath.FromSlash(dir)
+	}
+	entries, err := os.ReadDir(osDir)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading directory %s: %w", osDir, err)
+	}
+
+	var items []string
+	whole := true
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name())
+
+		if !entry.IsDir() {
+			// The .gogit file of a linked worktree.
+			if entry.Name() == ROOT || c.tracked[entryPath] || !c.spec.Match(entryPath) {
+				whole = false
+				continue
+			}
+			selected, err := c.selected(entryPath, false)
+			if err != nil {
+				return nil, false, err
+			}
+			if selected {
+				items = append(items, entryPath)
+			} else {
+				whole = false
+			}
+			continue
+		}
+
+		if entry.Name() == ROOT || entry.Name() == ".git" || isNestedRepo(entryPath) {
+			whole = false
+			continue
+		}
+		if !c.tracked[entryPath] && !c.opts.Directories {
+			whole = false
+			continue
+		}
+
+		subItems, subWhole, err := c.collect(entryPath)
+		if err != nil {
+			return nil, false, err
+		}
+		if c.tracked[entryPath] || !subWhole {
+			items = append(items, subItems...)
+			whole = false
+			continue
+		}
+
+		// An untracked directory that can go entirely is removed at once;
+		// an empty one only when it is selected itself.
+		if len(subItems) == 0 {
+			selected, err := c.selected(entryPath, true)
+			if err != nil {
+				return nil, false, err
+			}
+			if !selected || !c.spec.Match(entryPath) {
+				whole = false
+				continue
+			}
+		}
+		items = append(items, entryPath+"/")
+	}
+	return items, whole, nil
+}
+
+// selected reports whether an untracked path is to be removed, according to
+// the ignore rules.
+func (c *cleaner) selected(filePath string, isDir bool) (bool, error) {
+	ignored, err := c.ignore.IsIgnored(filePath, isDir)
+	if err != nil {
+		return false, err
+	}
+	if c.opts.OnlyIgnored {
+		return ignored, nil
+	}
+	return !ignored, nil
+}
+
+// isNestedRepo reports whether dir holds a repository or a linked worktree
+// of its own.
+func isNestedRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(filepath.FromSlash(dir), ROOT))
+	return err == nil
+}
+
+// cleanSession holds the prompt input and output of an interactive clean.
+type cleanSession struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// prompt prints a prompt and returns the answer. End of input returns
+// io.EOF.
+func (s *cleanSession) prompt(prompt string) (string, error) {
+	fmt.Fprintf(s.out, "%s%s%s ", ColorBlue, prompt, ColorReset)
+	answer, err := s.in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(s.out)
+		return "", io.EOF
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// run shows the menu until the user decides, and returns the items to
+// remove (none when the user quits).
+func (s *cleanSession) run(items []string) ([]string, error) {
+	for len(items) > 0 {
+		s.listItems(items, false)
+		fmt.Fprintln(s.out, "*** Commands ***")
+		var row string
+		for i, command := range cleanCommands {
+			row += fmt.Sprintf("    %d: %-20s", i+1, command)
+			if i%3 == 2 || i == len(cleanCommands)-1 {
+				fmt.Fprintln(s.out, strings.TrimRight(row, " "))
+				row = ""
+			}
+		}
+
+		answer, err := s.prompt("What now>")
+		if err == io.EOF {
+			answer = "quit"
+		}
+		switch s.command(answer) {
+		case "clean":
+			return items, nil
+		case "filter by pattern":
+			if items, err = s.filterByPattern(items); err != nil {
+				return nil, err
+			}
+		case "select by numbers":
+			if items, err = s.selectByNumbers(items); err != nil {
+				return nil, err
+			}
+		case "ask each":
+			return s.askEach(items)
+		case "quit":
+			fmt.Fprintln(s.out, "Bye.")
+			return nil, nil
+		case "help":
+			fmt.Fprint(s.out, cleanHelp)
+		case "":
+		default:
+			fmt.Fprintf(s.out, "Huh (%s)?\n", answer)
+		}
+	}
+
+	fmt.Fprintln(s.out, "No more files to clean, exiting.")
+	return nil, nil
+}
+
+// command resolves an answer of the menu: a number or a unique prefix.
+func (s *cleanSession) command(answer string) string {
+	if answer == "" {
+		return ""
+	}
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n >= 1 && n <= len(cleanCommands) {
+			return cleanCommands[n-1]
+		}
+		return answer
+	}
+
+	var found string
+	for _, command := range cleanCommands {
+		if strings.HasPrefix(command, strings.ToLower(answer)) {
+			if found != "" {
+				return answer
+			}
+			found = command
+		}
+	}
+	if found == "" {
+		return answer
+	}
+	return found
+}
+
+// listItems prints the items, numbered when the user picks them by number.
+func (s *cleanSession) listItems(items []string, numbered bool) {
+	if !numbered {
+		fmt.Fprintln(s.out, "Would remove the following items:")
+	}
+	for i, item := range items {
+		if numbered {
+			fmt.Fprintf(s.out, "    %d: %s\n", i+1, item)
+		} else {
+			fmt.Fprintf(s.out, "  %s\n", item)
+		}
+	}
+}
+
+// filterByPattern drops the items matching the ignore patterns the user
+// types, until an empty answer.
+func (s *cleanSession) filterByPattern(items []string) ([]string, error) {
+	for len(items) > 0 {
+		s.listItems(items, false)
+		answer, err := s.prompt("Input ignore patterns>>")
+		if err == io.EOF || answer == "" {
+			return items, nil
+		}
+
+		matcher := newPatternMatcher(strings.Fields(answer))
+		var kept []string
+		for _, item := range items {
+			ignored, err := matcher.IsIgnored(strings.TrimSuffix(item, "/"), strings.HasSuffix(item, "/"))
+			if err != nil {
+				return nil, err
+			}
+			if !ignored {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(items) {
+			fmt.Fprintf(s.out, "WARNING: Cannot find items matched by: %s\n", answer)
+		}
+		items = kept
+	}
+	return items, nil
+}
+
+// selectByNumbers keeps the items the user picks with numbers and ranges
+// such as "1 3-5", or "*" for all of them.
+func (s *cleanSession) selectByNumbers(items []string) ([]string, error) {
+	s.listItems(items, true)
+	answer, err := s.prompt("Select items to delete>>")
+	if err == io.EOF || answer == "" {
+		return items, nil
+	}
+
+	picked := make([]bool, len(items))
+	for _, field := range strings.Fields(strings.ReplaceAll(answer, ",", " ")) {
+		if field == "*" {
+			for i := range picked {
+				picked[i] = true
+			}
+			continue
+		}
+
+		first, last, isRange := strings.Cut(field, "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to = len(items)
+			if last != "" {
+				to, err = strconv.Atoi(last)
+			}
+		}
+		if err != nil || from < 1 || to > len(items) || from > to {
+			fmt.Fprintf(s.out, "Huh (%s)?\n", field)
+			continue
+		}
+		for i := from; i <= to; i++ {
+			picked[i-1] = true
+		}
+	}
+
+	var selected []string
+	for i, item := range items {
+		if picked[i] {
+			selected = append(selected, item)
+		}
+	}
+	return selected, nil
+}
+
+// askEach confirms every item in turn.
+func (s *cleanSession) askEach(items []string) ([]string, error) {
+	var confirmed []string
+	for _, item := range items {
+		answer, err := s.prompt(fmt.Sprintf("Remove %s [y/N]?", item))
+		if err == io.EOF {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			confirmed = append(confirmed, item)
+		}
+	}
+	return confirmed, nil
+}
@@ -1,5 +1,12 @@
 package gogit
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
 const (
 	ColorReset  = "\033[0m"
 	ColorRed    = "\033[31m"
@@ -7,3 +14,31 @@ const (
 	ColorYellow = "\033[33m"
 	ColorBlue   = "\033[34m"
 )
+
+// useColors tells whether output written to w is colored. color.ui set
+// to "always" or "never" (or "false") forces the choice; by default colors
+// are only used when w is a terminal.
+func useColors(w io.Writer) (bool, error) {
+	value, isSet, err := ConfigValue("color.ui")
+	if err != nil {
+		return false, err
+	}
+	if isSet {
+		switch strings.ToLower(value) {
+		case "always":
+			return true, nil
+		case "never", "false", "no", "off":
+			return false, nil
+		case "auto", "true", "yes", "on":
+		default:
+			return false, fmt.Errorf("fatal: bad color config value '%s' for 'color.ui'", value)
+		}
+	}
+
+	file, ok := w.(*os.File)
+	if !ok {
+		return false, nil
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+}
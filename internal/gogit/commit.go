@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,6 +18,13 @@ func AddCommit(message *string) error {
 		return nil
 	}
 
+	// Hold the index lock so that the tree is built from a consistent index.
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
 	indexMap, err := ReadIndex()
 	if err != nil {
 		return err
@@ -27,8 +35,21 @@ func AddCommit(message *string) error {
 		return nil
 	}
 
+	// Files added with `add -N` have no content to commit yet.
+	var intentToAdd []string
+	for path, entry := range indexMap {
+		if entry.IntentToAdd {
+			intentToAdd = append(intentToAdd, path)
+		}
+	}
+	if len(intentToAdd) > 0 {
+		sort.Strings(intentToAdd)
+		return fmt.Errorf("'%s' is only marked as intent-to-add\n"+
+			"hint: stage its content with 'gogit add' (or drop it with 'gogit rm --cached') before committing", intentToAdd[0])
+	}
+
 	// --- Generate and save the Tree object ---
-	treeHash, treeContent, err := HashTree(indexMap)
+	treeHash, treeContent, err := HashTree(IndexToTree(indexMap))
 	if err != nil {
 		return fmt.Errorf("error hashing tree: %w", err)
 	}
@@ -48,13 +69,20 @@ func AddCommit(message *string) error {
 	}
 	// --- End Tree object generation ---
 
-	headRef, err := GetHeadRef()
+	// Lock the branch ref (HEAD when detached) until it points at the new
+	// commit, so that a concurrent commit cannot be lost between reading the
+	// parent and writing.
+	var parentCommitHash string
+	branchRefPath, err := headTargetPath()
+	if err != nil {
+		return err
+	}
+	refLock, err := AcquireLock(branchRefPath)
 	if err != nil {
 		return err
 	}
+	defer refLock.Rollback()
 
-	var parentCommitHash string
-	branchRefPath := fmt.Sprintf("%s/%s", RepoPath, headRef["ref:"])
 	branchRef, err := os.Open(branchRefPath)
 	if err != nil {
 		// If the branch ref file doesn't exist, it's likely the first commit.
@@ -96,8 +124,7 @@ func AddCommit(message *string) error {
 	}
 
 	// Update branch reference (e.g., refs/heads/main)
-	newRefHeadPath := filepath.Join(RepoPath, headRef["ref:"])
-	if err := os.WriteFile(newRefHeadPath, []byte(commitHash+"\n"), 0644); err != nil {
+	if err := refLock.Commit([]byte(commitHash + "\n")); err != nil {
 		return fmt.Errorf("error updating branch reference file: %w", err)
 	}
 
@@ -4,15 +4,34 @@ import (
 	"path/filepath"
 )
 
+// The paths of the repository, relative to the root of the working tree
+// unless it is a linked worktree. RepoPath holds the files of the working
+// tree (HEAD, index, ...) and CommonPath the ones all the worktrees of a
+// repository share (objects, refs, config); they are the same directory for
+// the main working tree. See setRepoPaths.
 var (
-	RepoPath         = filepath.Join(".", ".gogit")
-	ObjectsPath      = filepath.Join(RepoPath, "objects")
-	IndexPath        = filepath.Join(RepoPath, "index")
-	HeadPath         = filepath.Join(RepoPath, "HEAD")
-	RefHeadsPath     = filepath.Join(RepoPath, "refs/heads")
-	RefHeadsMainPath = filepath.Join(RepoPath, "refs/heads/main")
-	IgnorePath       = filepath.Join(".gogitignore")
-	ConfigPath       = filepath.Join("~/.gogitconfig")
+	RepoPath         string
+	CommonPath       string
+	ObjectsPath      string
+	IndexPath        string
+	HeadPath         string
+	RefHeadsPath     string
+	RefHeadsMainPath string
+	RefRemotesPath   string
+	RepoConfigPath   string
+	InfoPath         string
+	SparsePath       string
+	ExcludePath      string
+	AttributesPath   string
+	WorktreesPath    string
+	FsmonitorPath    string
+	FsmonitorLogPath string
+	FsmonitorCache   string
+)
+
+var (
+	IgnorePath = filepath.Join(".gogitignore")
+	ConfigPath = filepath.Join("~/.gogitconfig")
 
 	ROOT          = ".gogit"
 	OBJECTS       = "objects"
@@ -21,3 +40,49 @@ var (
 	INDEX         = "index"
 	GLOBAL_CONFIG = ".gogitconfig"
 )
+
+func init() {
+	repoPath := filepath.Join(".", ROOT)
+	setRepoPaths(repoPath, repoPath)
+}
+
+// setRepoPaths points the repository paths at gitDir, the directory of the
+// current working tree, and commonDir, the repository it belongs to.
+func setRepoPaths(gitDir, commonDir string) {
+	RepoPath = gitDir
+	CommonPath = commonDir
+
+	IndexPath = filepath.Join(gitDir, "index")
+	HeadPath = filepath.Join(gitDir, "HEAD")
+	SparsePath = filepath.Join(gitDir, "info", "sparse-checkout")
+	FsmonitorPath = filepath.Join(gitDir, "fsmonitor--daemon.ipc")
+	FsmonitorLogPath = filepath.Join(gitDir, "fsmonitor--daemon.log")
+	FsmonitorCache = filepath.Join(gitDir, "fsmonitor-cache")
+
+	ObjectsPath = filepath.Join(commonDir, "objects")
+	RefHeadsPath = filepath.Join(commonDir, "refs/heads")
+	RefHeadsMainPath = filepath.Join(commonDir, "refs/heads/main")
+	RefRemotesPath = filepath.Join(commonDir, "refs/remotes")
+	RepoConfigPath = filepath.Join(commonDir, "config")
+	InfoPath = filepath.Join(commonDir, "info")
+	ExcludePath = filepath.Join(InfoPath, "exclude")
+	AttributesPath = filepath.Join(InfoPath, "attributes")
+	WorktreesPath = filepath.Join(commonDir, "worktrees")
+}
+
+// EmptyBlobHash is the hash of the blob with no content.
+const EmptyBlobHash = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// Flags stored after the mode and hash of an index line.
+const (
+	indexFlagIntentToAdd     = "intent-to-add"
+	indexFlagSkipWorktree    = "skip-worktree"
+	indexFlagAssumeUnchanged = "assume-unchanged"
+)
+
+// File modes recorded in the index and in tree objects.
+const (
+	ModeRegular    = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+)
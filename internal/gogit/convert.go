@@ -0,0 +1,249 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// textMode is what the text attribute says of a file.
+type textMode int
+
+const (
+	textBinary textMode = iota // no conversion
+	textAlways                 // text: always normalized
+	textAuto                   // text=auto: normalized unless the content is binary
+)
+
+// fileConversion is how a file is converted between the working tree and
+// the object store: its filter driver, if any, and its line endings, turned
+// to LF when it is staged and to CRLF when it is checked out if crlf is set.
+type fileConversion struct {
+	path   string
+	filter *filterDriver
+	mode   textMode
+	crlf   bool
+}
+
+// converter converts files between the working tree and the object store
+// according to their attributes.
+type converter struct {
+	attributes *AttributeMatcher
+	crlf       bool // core.eol asks for CRLF in the working tree
+
+	mu      sync.Mutex
+	filters map[string]*filterDriver // by name, nil when not configured
+}
+
+var (
+	converterMu     sync.Mutex
+	worktreeConvert *converter
+)
+
+// worktreeConverter returns the converter of the working tree, loading the
+// attribute files the first time.
+func worktreeConverter() (*converter, error) {
+	converterMu.Lock()
+	defer converterMu.Unlock()
+
+	if worktreeConvert != nil {
+		return worktreeConvert, nil
+	}
+
+	attributes, err := NewAttributeMatcher()
+	if err != nil {
+		return nil, err
+	}
+	crlf, err := coreEOLIsCRLF()
+	if err != nil {
+		return nil, err
+	}
+	worktreeConvert = &converter{attributes: attributes, crlf: crlf, filters: make(map[string]*filterDriver)}
+	return worktreeConvert, nil
+}
+
+// reloadAttributes forgets the attribute files read so far, for when a
+// checkout has just written new ones.
+func reloadAttributes() {
+	converterMu.Lock()
+	defer converterMu.Unlock()
+	worktreeConvert = nil
+}
+
+// coreEOLIsCRLF reports whether core.eol ("lf", "crlf" or "native", the
+// default) asks for CRLF line endings.
+func coreEOLIsCRLF() (bool, error) {
+	value, isSet, err := ConfigValue("core.eol")
+	if err != nil {
+		return false, err
+	}
+	if !isSet {
+		value = "native"
+	}
+
+	switch strings.ToLower(value) {
+	case "lf":
+		return false, nil
+	case "crlf":
+		return true, nil
+	case "native":
+		return runtime.GOOS == "windows", nil
+	default:
+		return false, fmt.Errorf("fatal: core.eol must be one of lf, crlf or native, not '%s'", value)
+	}
+}
+
+// conversion returns how filePath is converted. "filter=<name>" runs the
+// filter driver configured as filter.<name>. "text" normalizes the line
+// endings, "text=auto" only when the file does not look binary, "-text" (or
+// "binary") never. "eol=lf" and "eol=crlf" set the line endings of the
+// checked out file, and imply "text" when it is unspecified; other text
+// files get the ones of core.eol.
+func (c *converter) conversion(filePath string) (fileConversion, error) {
+	attrs, err := c.attributes.Attributes(filePath)
+	if err != nil {
+		return fileConversion{}, err
+	}
+
+	conv := fileConversion{path: filePath, crlf: c.crlf}
+	if name, hasFilter := attrs["filter"]; hasFilter && name != AttrSet && name != AttrUnset {
+		if conv.filter, err = c.filterDriver(name); err != nil {
+			return fileConversion{}, err
+		}
+	}
+
+	eol, hasEOL := attrs["eol"]
+	switch eol {
+	case "lf":
+		conv.crlf = false
+	case "crlf":
+		conv.crlf = true
+	default:
+		hasEOL = false
+	}
+
+	switch text, hasText := attrs["text"]; {
+	case text == AttrSet:
+		conv.mode = textAlways
+	case text == "auto":
+		conv.mode = textAuto
+	case !hasText && hasEOL:
+		conv.mode = textAlways
+	default:
+		conv.mode = textBinary
+	}
+	return conv, nil
+}
+
+// filterDriver returns the driver configured for a filter name, loading it
+// the first time.
+func (c *converter) filterDriver(name string) (*filterDriver, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if driver, loaded := c.filters[name]; loaded {
+		return driver, nil
+	}
+	driver, err := loadFilterDriver(name)
+	if err != nil {
+		return nil, err
+	}
+	c.filters[name] = driver
+	return driver, nil
+}
+
+// toObject converts the content of a working tree file to the content of
+// its blob: the clean filter runs first, then the line endings are
+// normalized.
+func (conv fileConversion) toObject(content []byte) ([]byte, error) {
+	if conv.filter != nil {
+		var err error
+		if content, err = conv.filter.apply(filterClean, conv.path, content); err != nil {
+			return nil, err
+		}
+	}
+
+	if conv.mode == textBinary || !bytes.Contains(content, []byte("\r\n")) {
+		return content, nil
+	}
+	if conv.mode == textAuto && looksBinary(content) {
+		return content, nil
+	}
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+}
+
+// toWorktree converts the content of a blob to the content of the file
+// checked out: the line endings are converted first, then the smudge filter
+// runs. Line endings already in CRLF are kept as they are.
+func (conv fileConversion) toWorktree(content []byte) ([]byte, error) {
+	content = conv.worktreeLineEndings(content)
+	if conv.filter == nil {
+		return content, nil
+	}
+	return conv.filter.apply(filterSmudge, conv.path, content)
+}
+
+// worktreeLineEndings turns the LF line endings of a text file into CRLF
+// when the file is to have them.
+func (conv fileConversion) worktreeLineEndings(content []byte) []byte {
+	if conv.mode == textBinary || !conv.crlf || !bytes.Contains(content, []byte("\n")) {
+		return content
+	}
+	if conv.mode == textAuto && (looksBinary(content) || bytes.Contains(content, []byte("\r\n"))) {
+		return content
+	}
+
+	var converted bytes.Buffer
+	converted.Grow(len(content) + bytes.Count(content, []byte("\n")))
+	for i, b := range content {
+		if b == '\n' && (i == 0 || content[i-1] != '\r') {
+			converted.WriteByte('\r')
+		}
+		converted.WriteByte(b)
+	}
+	return converted.Bytes()
+}
+
+// looksBinary tells text=auto which files to leave alone: the ones isBinary
+// detects, and the ones with a carriage return that does not end a line.
+func looksBinary(content []byte) bool {
+	if isBinary(content) {
+		return true
+	}
+	for i, b := range content {
+		if b == '\r' && (i+1 == len(content) || content[i+1] != '\n') {
+			return true
+		}
+	}
+	return false
+}
+
+// convertToObject applies the attributes of filePath to the content read
+// from the working tree.
+func convertToObject(filePath string, content []byte) ([]byte, error) {
+	c, err := worktreeConverter()
+	if err != nil {
+		return nil, err
+	}
+	conv, err := c.conversion(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return conv.toObject(content)
+}
+
+// convertToWorktree applies the attributes of filePath to the content of
+// its blob.
+func convertToWorktree(filePath string, content []byte) ([]byte, error) {
+	c, err := worktreeConverter()
+	if err != nil {
+		return nil, err
+	}
+	conv, err := c.conversion(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return conv.toWorktree(content)
+}
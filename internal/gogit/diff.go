@@ -0,0 +1,522 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around changes.
+const diffContextLines = 3
+
+// diffOp is the kind of a line in an edit script.
+type diffOp byte
+
+const (
+	opEqual  diffOp = ' '
+	opDelete diffOp = '-'
+	opInsert diffOp = '+'
+)
+
+// diffLine is one line of an edit script. text keeps its trailing newline,
+// so a last line without one is told apart.
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// hunk is a range [start, end) of an edit script shown as a unit.
+type hunk struct {
+	start, end int
+}
+
+// splitLines splits content into lines, each keeping its "\n".
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// isBinary uses Git's heuristic: a NUL byte in the first 8000 bytes.
+func isBinary(content []byte) bool {
+	const sniffLen = 8000
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// diffLines computes the shortest edit script turning a into b using
+// Myers' O(ND) algorithm, after trimming the common prefix and suffix.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var script []diffLine
+	for _, line := range a[:prefix] {
+		script = append(script, diffLine{opEqual, line})
+	}
+	script = append(script, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		script = append(script, diffLine{opEqual, line})
+	}
+	return script
+}
+
+func myers(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	// trace[d] holds v[-d..d] as it was at the start of step d.
+	var trace [][]int
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script.
+	var reversed []diffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffLine{opInsert, b[y-1]})
+				y--
+			} else {
+				reversed = append(reversed, diffLine{opDelete, a[x-1]})
+				x--
+			}
+		}
+	}
+
+	script := make([]diffLine, len(reversed))
+	for i, line := range reversed {
+		script[len(reversed)-1-i] = line
+	}
+	return script
+}
+
+// makeHunks groups the changes of script into hunks with up to context
+// unchanged lines around them. Changes closer than 2*context lines share a
+// hunk, so hunks never overlap.
+func makeHunks(script []diffLine, context int) []hunk {
+	var hunks []hunk
+	for i := 0; i < len(script); {
+		if script[i].op == opEqual {
+			i++
+			continue
+		}
+
+		start := max(i-context, 0)
+		if len(hunks) > 0 && start < hunks[len(hunks)-1].end {
+			start = hunks[len(hunks)-1].end
+		}
+
+		// Extend over changes separated by at most 2*context equal lines.
+		end := i
+		for end < len(script) {
+			if script[end].op != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].op == opEqual {
+				run++
+			}
+			if run == len(script) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+
+		i = end
+		end = min(end+context, len(script))
+		hunks = append(hunks, hunk{start: start, end: end})
+	}
+	return hunks
+}
+
+// splitHunk splits h at the unchanged lines separating its groups of
+// changes. The shared context is divided between neighbours so the pieces
+// stay disjoint. It returns h alone when it cannot be split.
+func splitHunk(script []diffLine, h hunk) []hunk {
+	// Find the runs of changes inside the hunk.
+	type run struct{ start, end int }
+	var runs []run
+	for i := h.start; i < h.end; {
+		if script[i].op == opEqual {
+			i++
+			continue
+		}
+		j := i
+		for j < h.end && script[j].op != opEqual {
+			j++
+		}
+		runs = append(runs, run{i, j})
+		i = j
+	}
+	if len(runs) < 2 {
+		return []hunk{h}
+	}
+
+	pieces := make([]hunk, len(runs))
+	start := h.start
+	for i := range runs {
+		end := h.end
+		if i+1 < len(runs) {
+			gap := runs[i+1].start - runs[i].end
+			end = runs[i].end + (gap+1)/2
+		}
+		pieces[i] = hunk{start: start, end: end}
+		start = end
+	}
+	return pieces
+}
+
+// hunkHeader returns the "@@ -a,b +c,d @@" line of h.
+func hunkHeader(script []diffLine, h hunk) string {
+	oldStart, newStart := 1, 1
+	for _, line := range script[:h.start] {
+		if line.op != opInsert {
+			oldStart++
+		}
+		if line.op != opDelete {
+			newStart++
+		}
+	}
+
+	oldLines, newLines := 0, 0
+	for _, line := range script[h.start:h.end] {
+		if line.op != opInsert {
+			oldLines++
+		}
+		if line.op != opDelete {
+			newLines++
+		}
+	}
+
+	// An empty side is numbered after the line it follows, like Git does.
+	if oldLines == 0 {
+		oldStart--
+	}
+	if newLines == 0 {
+		newStart--
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldLines), hunkRange(newStart, newLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// writeHunk prints the header and the lines of h in unified format, in
+// color when colors is set.
+func writeHunk(w io.Writer, script []diffLine, h hunk, colors bool) {
+	paint := func(text, color string) string {
+		if !colors {
+			return text
+		}
+		return colorize(text, color)
+	}
+
+	fmt.Fprintln(w, paint(hunkHeader(script, h), ColorBlue))
+	for _, line := range script[h.start:h.end] {
+		text := strings.TrimSuffix(line.text, "\n")
+		switch line.op {
+		case opDelete:
+			fmt.Fprintln(w, paint("-"+text, ColorRed))
+		case opInsert:
+			fmt.Fprintln(w, paint("+"+text, ColorGreen))
+		default:
+			fmt.Fprintf(w, " %s\n", text)
+		}
+		if !strings.HasSuffix(line.text, "\n") {
+			fmt.Fprintln(w, "\\ No newline at end of file")
+		}
+	}
+}
+
+// applySelected rebuilds a file from script, keeping the old side except for
+// the changes marked as accepted.
+func applySelected(script []diffLine, accepted []bool) []byte {
+	var buffer bytes.Buffer
+	for i, line := range script {
+		switch {
+		case line.op == opEqual:
+			buffer.WriteString(line.text)
+		case line.op == opDelete && !accepted[i]:
+			buffer.WriteString(line.text)
+		case line.op == opInsert && accepted[i]:
+			buffer.WriteString(line.text)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// diffSide is one side of a file diff; a nil entry means the file is absent.
+type diffSide struct {
+	entry   *TreeEntry
+	content []byte
+}
+
+// writeFileDiff prints the unified diff of a changed file between two
+// sides, with the rename or copy header when it has a source.
+func writeFileDiff(w io.Writer, change fileChange, oldSide, newSide diffSide, colors bool) {
+	oldPath, newPath := change.path, change.path
+	if change.origPath != "" {
+		oldPath = change.origPath
+	}
+	fmt.Fprintf(w, "diff --gogit a/%s b/%s\n", oldPath, newPath)
+	if change.origPath != "" {
+		verb := "rename"
+		if change.status == StatusCopied {
+			verb = "copy"
+		}
+		fmt.Fprintf(w, "similarity index %d%%\n%s from %s\n%s to %s\n", change.score, verb, oldPath, verb, newPath)
+	}
+
+	oldHash, newHash := "0000000", "0000000"
+	oldName, newName := "a/"+oldPath, "b/"+newPath
+	switch {
+	case oldSide.entry == nil:
+		fmt.Fprintf(w, "new file mode %s\n", newSide.entry.Mode)
+		newHash = ShortHash(newSide.entry.Hash)
+		oldName = "/dev/null"
+		fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+	case newSide.entry == nil:
+		fmt.Fprintf(w, "deleted file mode %s\n", oldSide.entry.Mode)
+		oldHash = ShortHash(oldSide.entry.Hash)
+		newName = "/dev/null"
+		fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+	default:
+		oldHash, newHash = ShortHash(oldSide.entry.Hash), ShortHash(newSide.entry.Hash)
+		if oldSide.entry.Mode != newSide.entry.Mode {
+			fmt.Fprintf(w, "old mode %s\nnew mode %s\n", oldSide.entry.Mode, newSide.entry.Mode)
+			if oldSide.entry.Hash == newSide.entry.Hash {
+				return
+			}
+			fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+		} else {
+			if oldSide.entry.Hash == newSide.entry.Hash {
+				// An exact rename or copy has no content change to show.
+				return
+			}
+			fmt.Fprintf(w, "index %s..%s %s\n", oldHash, newHash, oldSide.entry.Mode)
+		}
+	}
+
+	if isBinary(oldSide.content) || isBinary(newSide.content) {
+		fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	script := diffLines(splitLines(oldSide.content), splitLines(newSide.content))
+	for _, h := range makeHunks(script, diffContextLines) {
+		writeHunk(w, script, h, colors)
+	}
+}
+
+// DiffOptions controls what Diff compares and how.
+type DiffOptions struct {
+	// Cached compares HEAD with the index instead of the index with the
+	// working tree.
+	Cached bool
+	// NameStatus only lists the changed paths with their status letter.
+	NameStatus bool
+	// Renames sets the detection of renamed and copied files.
+	Renames RenameOptions
+}
+
+// Diff prints the changes between the index and the working tree, or
+// between HEAD and the index when opts.Cached is set, for the paths selected
+// by the pathspecs.
+func Diff(pathspecs []string, opts DiffOptions) error {
+	spec, err := ParsePathspec(pathspecs)
+	if err != nil {
+		return err
+	}
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	// Both sides are restricted to the pathspec before renames are paired.
+	oldTree := make(map[string]TreeEntry)
+	newTree := make(map[string]TreeEntry)
+	var loadNew func(path string) ([]byte, error)
+	if opts.Cached {
+		headTree, err := ReadHeadTree()
+		if err != nil {
+			return fmt.Errorf("reading HEAD tree: %w", err)
+		}
+		for path, entry := range headTree {
+			if spec.Match(path) {
+				oldTree[path] = entry
+			}
+		}
+		for path, entry := range IndexToTree(indexEntries) {
+			if spec.Match(path) {
+				newTree[path] = entry
+			}
+		}
+	} else {
+		for path, entry := range indexEntries {
+			if entry.ignoresWorktree() || !spec.Match(path) {
+				continue
+			}
+			if !entry.IntentToAdd {
+				oldTree[path] = TreeEntry{Mode: entry.Mode, Hash: entry.Hash}
+			}
+			side, err := worktreeDiffSide(path)
+			if err != nil {
+				return err
+			}
+			if side.entry != nil {
+				newTree[path] = *side.entry
+			}
+		}
+		loadNew = func(path string) ([]byte, error) {
+			content, _, err := readWorktreeBlob(path)
+			return content, err
+		}
+	}
+
+	changes, err := diffTrees(oldTree, newTree, opts.Renames, loadNew)
+	if err != nil {
+		return err
+	}
+	colors, err := useColors(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if opts.NameStatus {
+			fmt.Println(change.nameStatus())
+			continue
+		}
+
+		oldPath := change.path
+		if change.origPath != "" {
+			oldPath = change.origPath
+		}
+		oldSide, err := treeDiffSide(oldTree, oldPath)
+		if err != nil {
+			return err
+		}
+		var newSide diffSide
+		if opts.Cached {
+			newSide, err = treeDiffSide(newTree, change.path)
+		} else {
+			newSide, err = worktreeDiffSide(change.path)
+		}
+		if err != nil {
+			return err
+		}
+		if change.origPath == "" && sameDiffSide(oldSide, newSide) {
+			continue
+		}
+		writeFileDiff(os.Stdout, change, oldSide, newSide, colors)
+	}
+	return nil
+}
+
+// treeDiffSide loads path from a tree (or the index seen as a tree).
+func treeDiffSide(tree map[string]TreeEntry, path string) (diffSide, error) {
+	entry, exists := tree[path]
+	if !exists {
+		return diffSide{}, nil
+	}
+	content, err := readObjectContent(entry.Hash)
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{entry: &entry, content: content}, nil
+}
+
+// worktreeDiffSide loads path from the working tree.
+func worktreeDiffSide(path string) (diffSide, error) {
+	content, mode, err := readWorktreeBlob(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return diffSide{}, nil
+		}
+		return diffSide{}, err
+	}
+
+	blobHash, _, err := HashObject(content)
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{entry: &TreeEntry{Mode: mode, Hash: blobHash}, content: content}, nil
+}
+
+func sameDiffSide(a, b diffSide) bool {
+	if a.entry == nil || b.entry == nil {
+		return a.entry == b.entry
+	}
+	return *a.entry == *b.entry
+}
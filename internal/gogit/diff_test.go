@@ -0,0 +1,323 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// linesOf splits a space separated list of words into lines.
+func linesOf(words string) []string {
+	var lines []string
+	for _, word := range strings.Fields(words) {
+		lines = append(lines, word+"\n")
+	}
+	return lines
+}
+
+// renderScript shows an edit script as "op+word" items, e.g. " a -b +c".
+func renderScript(script []diffLine) string {
+	var items []string
+	for _, line := range script {
+		items = append(items, string(line.op)+strings.TrimSuffix(line.text, "\n"))
+	}
+	return strings.Join(items, " ")
+}
+
+// scriptOf builds an edit script from one op per line: '=' for an
+// unchanged line, '-' and '+' for removed and added ones.
+func scriptOf(ops string) []diffLine {
+	script := make([]diffLine, len(ops))
+	for i, op := range ops {
+		line := diffLine{op: opEqual, text: fmt.Sprintf("%d\n", i)}
+		switch op {
+		case '-':
+			line.op = opDelete
+		case '+':
+			line.op = opInsert
+		}
+		script[i] = line
+	}
+	return script
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"", nil},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n\nb\n", []string{"a\n", "\n", "b\n"}},
+	}
+	for _, tt := range tests {
+		if got := splitLines([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if isBinary([]byte("plain text\n")) {
+		t.Error("isBinary(text) = true")
+	}
+	if !isBinary([]byte("a\x00b")) {
+		t.Error("isBinary(NUL) = false")
+	}
+	// Only the first 8000 bytes are looked at.
+	if isBinary(append(bytes.Repeat([]byte("a"), 8000), 0)) {
+		t.Error("isBinary(NUL after 8000 bytes) = true")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"a b c", "a b c", " a  b  c"},
+		{"", "x", "+x"},
+		{"x", "", "-x"},
+		{"a b c", "a c", " a -b  c"},
+		{"a c", "a b c", " a +b  c"},
+		{"a b c", "a B c", " a -b +B  c"},
+		{"a b c d", "b c d e", "-a  b  c  d +e"},
+	}
+	for _, tt := range tests {
+		got := renderScript(diffLines(linesOf(tt.a), linesOf(tt.b)))
+		if got != tt.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestDiffLinesMinimal checks that the scripts turn a into b with the
+// smallest number of changes on the classic example of Myers' paper.
+func TestDiffLinesMinimal(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int
+	}{
+		{"a b c a b b a", "c b a b a c", 5},
+		{"a b c d e f", "f e d c b a", 10},
+		{"x a y b z c", "a b c", 3},
+	}
+	for _, tt := range tests {
+		a, b := linesOf(tt.a), linesOf(tt.b)
+		script := diffLines(a, b)
+
+		var oldSide, newSide []string
+		changes := 0
+		for _, line := range script {
+			if line.op != opInsert {
+				oldSide = append(oldSide, line.text)
+			}
+			if line.op != opDelete {
+				newSide = append(newSide, line.text)
+			}
+			if line.op != opEqual {
+				changes++
+			}
+		}
+		if !reflect.DeepEqual(oldSide, a) || !reflect.DeepEqual(newSide, b) {
+			t.Errorf("diffLines(%q, %q) = %q does not rebuild both sides", tt.a, tt.b, renderScript(script))
+		}
+		if changes != tt.changes {
+			t.Errorf("diffLines(%q, %q) has %d changes, want %d", tt.a, tt.b, changes, tt.changes)
+		}
+	}
+}
+
+func TestMakeHunks(t *testing.T) {
+	tests := []struct {
+		ops  string
+		want []hunk
+	}{
+		{"==========", nil},
+		{"=====-=====", []hunk{{2, 9}}},
+		{"-==========", []hunk{{0, 4}}},
+		{"==========+", []hunk{{7, 11}}},
+		// Changes up to 2*context lines apart share a hunk.
+		{"=-======-=", []hunk{{0, 10}}},
+		{"=-=======-=", []hunk{{0, 5}, {6, 11}}},
+		{"-+=-+", []hunk{{0, 5}}},
+	}
+	for _, tt := range tests {
+		if got := makeHunks(scriptOf(tt.ops), 3); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("makeHunks(%q) = %v, want %v", tt.ops, got, tt.want)
+		}
+	}
+}
+
+func TestSplitHunk(t *testing.T) {
+	tests := []struct {
+		ops  string
+		h    hunk
+		want []hunk
+	}{
+		// A single group of changes cannot be split.
+		{"=-+=", hunk{0, 4}, []hunk{{0, 4}}},
+		{"=-=+=", hunk{0, 5}, []hunk{{0, 3}, {3, 5}}},
+		{"=-======-=", hunk{0, 10}, []hunk{{0, 5}, {5, 10}}},
+		{"=-==+==-=", hunk{0, 9}, []hunk{{0, 3}, {3, 6}, {6, 9}}},
+	}
+	for _, tt := range tests {
+		got := splitHunk(scriptOf(tt.ops), tt.h)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitHunk(%q, %v) = %v, want %v", tt.ops, tt.h, got, tt.want)
+			continue
+		}
+		// The pieces must cover the hunk exactly.
+		if got[0].start != tt.h.start || got[len(got)-1].end != tt.h.end {
+			t.Errorf("splitHunk(%q, %v) = %v does not cover the hunk", tt.ops, tt.h, got)
+		}
+	}
+}
+
+func TestHunkHeader(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"a b c", "a c", "@@ -1,3 +1,2 @@"},
+		{"", "x", "@@ -0,0 +1 @@"},
+		{"x", "", "@@ -1 +0,0 @@"},
+		{"1 2 3 4 5 6 7 8 9", "1 2 3 4 5 6 7 8 X 9", "@@ -6,4 +6,5 @@"},
+	}
+	for _, tt := range tests {
+		script := diffLines(linesOf(tt.a), linesOf(tt.b))
+		hunks := makeHunks(script, diffContextLines)
+		if len(hunks) != 1 {
+			t.Errorf("makeHunks(%q, %q) = %v, want one hunk", tt.a, tt.b, hunks)
+			continue
+		}
+		if got := hunkHeader(script, hunks[0]); got != tt.want {
+			t.Errorf("hunkHeader(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHunk(t *testing.T) {
+	script := diffLines([]string{"a\n", "b"}, []string{"a\n", "c\n"})
+	hunks := makeHunks(script, diffContextLines)
+
+	var out bytes.Buffer
+	writeHunk(&out, script, hunks[0], false)
+	want := "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n"
+	if out.String() != want {
+		t.Errorf("writeHunk() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	writeHunk(&out, script, hunks[0], true)
+	if !strings.Contains(out.String(), ColorRed+"-b"+ColorReset) {
+		t.Errorf("writeHunk() with colors = %q, want a red removed line", out.String())
+	}
+}
+
+func TestApplySelected(t *testing.T) {
+	script := diffLines(linesOf("a b c"), linesOf("a B c d"))
+	tests := []struct {
+		accept string // the words of the changes to accept
+		want   string
+	}{
+		{"", "a b c"},
+		{"b B d", "a B c d"},
+		{"d", "a b c d"},
+		{"b", "a c"},
+		{"B", "a b B c"},
+	}
+	for _, tt := range tests {
+		accepted := make([]bool, len(script))
+		for i, line := range script {
+			for _, word := range strings.Fields(tt.accept) {
+				if line.op != opEqual && line.text == word+"\n" {
+					accepted[i] = true
+				}
+			}
+		}
+		got := string(applySelected(script, accepted))
+		if want := strings.Join(linesOf(tt.want), ""); got != want {
+			t.Errorf("applySelected(accepting %q) = %q, want %q", tt.accept, got, want)
+		}
+	}
+}
+
+func TestSpliceHunk(t *testing.T) {
+	script := scriptOf("=-=======+=")
+	hunks := makeHunks(script, 3)
+	if len(hunks) != 2 {
+		t.Fatalf("makeHunks() = %v, want two hunks", hunks)
+	}
+	accepted := make([]bool, len(script))
+
+	// The first hunk is replaced by one that also adds a line.
+	edited := append(append([]diffLine(nil), script[hunks[0].start:hunks[0].end]...), diffLine{opInsert, "new\n"})
+	shift := len(edited) - (hunks[0].end - hunks[0].start)
+	second := hunks[1]
+
+	newScript, newAccepted, newHunks := spliceHunk(script, accepted, hunks, 0, edited)
+	if len(newScript) != len(script)+shift || len(newAccepted) != len(newScript) {
+		t.Fatalf("spliceHunk() gave %d lines and %d flags, want %d", len(newScript), len(newAccepted), len(script)+shift)
+	}
+	if newHunks[1] != (hunk{second.start + shift, second.end + shift}) {
+		t.Errorf("second hunk = %v, want it shifted by %d from %v", newHunks[1], shift, second)
+	}
+	for i, line := range edited {
+		if newAccepted[hunks[0].start+i] != (line.op != opEqual) {
+			t.Errorf("edited line %d accepted = %v", i, newAccepted[hunks[0].start+i])
+		}
+	}
+	if newScript[newHunks[1].start+3] != script[second.start+3] {
+		t.Errorf("second hunk content moved: %q", renderScript(newScript))
+	}
+}
+
+func TestEditHunk(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("sed is not available")
+	}
+	savedRepoPath := RepoPath
+	RepoPath = t.TempDir()
+	t.Cleanup(func() { RepoPath = savedRepoPath })
+
+	script := diffLines(linesOf("a b c"), linesOf("a B c"))
+	h := makeHunks(script, diffContextLines)[0]
+
+	tests := []struct {
+		name    string
+		editor  string
+		want    string
+		wantErr bool
+	}{
+		{"unchanged", "true", " a -b +B  c", false},
+		{"drop an added line", `sed -i "/^+B/d"`, " a -b  c", false},
+		{"keep a removed line", `sed -i "s/^-b/ b/"`, " a  b +B  c", false},
+		{"add a line", `sed -i "/^+B/a +extra"`, " a -b +B +extra  c", false},
+		{"change the old side", `sed -i "s/^ a/ z/"`, "", true},
+		{"bad line", `sed -i "s/^ a/?a/"`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOGIT_EDITOR", tt.editor)
+			edited, err := editHunk(script, h)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("editHunk() = %q, want an error", renderScript(edited))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := renderScript(edited); got != tt.want {
+				t.Errorf("editHunk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package gogit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileMode returns the mode gogit records for a file: symlinks are stored as
+// 120000, files with any executable bit as 100755 and everything else as 100644.
+func fileMode(info fs.FileInfo) string {
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return ModeSymlink
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		return ModeExecutable
+	}
+	return ModeRegular
+}
+
+// readWorktreeFile reads a file from the working directory without following
+// symlinks. For a symlink the content is the link target, like Git does.
+func readWorktreeFile(path string) ([]byte, string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mode := fileMode(info)
+	if mode == ModeSymlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(filepath.ToSlash(target)), mode, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, mode, nil
+}
+
+// readWorktreeBlob reads a file from the working directory like
+// readWorktreeFile and returns the content its blob would have, with the
+// line endings normalized as its attributes ask.
+func readWorktreeBlob(path string) ([]byte, string, error) {
+	content, mode, err := readWorktreeFile(path)
+	if err != nil || mode == ModeSymlink {
+		return content, mode, err
+	}
+
+	content, err = convertToObject(path, content)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, mode, nil
+}
+
+// writeWorktreeFile materializes the blob of entry at path, recreating
+// symlinks and the executable bit according to the entry mode. The line
+// endings of regular files are converted as their attributes ask.
+func writeWorktreeFile(path string, entry TreeEntry) error {
+	blobContent, err := readObjectContent(entry.Hash)
+	if err != nil {
+		return fmt.Errorf("error reading blob object %s: %w", entry.Hash, err)
+	}
+	if entry.Mode != ModeSymlink {
+		if blobContent, err = convertToWorktree(path, blobContent); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directories for %s: %w", path, err)
+	}
+
+	// Remove whatever is there first: writing through an existing symlink
+	// would modify its target, and WriteFile keeps the old permissions.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error replacing file %s: %w", path, err)
+	}
+
+	switch entry.Mode {
+	case ModeSymlink:
+		if err := os.Symlink(filepath.FromSlash(string(blobContent)), path); err != nil {
+			return fmt.Errorf("error creating symlink %s: %w", path, err)
+		}
+	case ModeExecutable:
+		if err := os.WriteFile(path, blobContent, 0755); err != nil {
+			return fmt.Errorf("error writing file %s: %w", path, err)
+		}
+	default:
+		if err := os.WriteFile(path, blobContent, 0644); err != nil {
+			return fmt.Errorf("error writing file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// hashWorktreeFile returns the entry (mode and blob hash) that the file at
+// path would have if it were staged, without writing any object.
+func hashWorktreeFile(path string) (TreeEntry, error) {
+	content, mode, err := readWorktreeBlob(path)
+	if err != nil {
+		return TreeEntry{}, err
+	}
+
+	blobHash, _, err := HashObject(content)
+	if err != nil {
+		return TreeEntry{}, err
+	}
+
+	return TreeEntry{Mode: mode, Hash: blobHash}, nil
+}
+
+// pathInScope reports whether path is scope itself or lies inside the
+// directory scope. The scope "." covers the whole working tree.
+func pathInScope(path, scope string) bool {
+	if scope == "." || scope == "" {
+		return true
+	}
+	return path == scope || strings.HasPrefix(path, scope+"/")
+}
+
+// removeEmptyParents removes the now empty directories above path,
+// stopping at the first one that still has content.
+func removeEmptyParents(path string) {
+	dir := filepath.Dir(path)
+	for dir != "." && dir != string(filepath.Separator) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
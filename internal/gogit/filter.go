@@ -0,0 +1,414 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The commands of a filter driver.
+const (
+	filterClean  = "clean"
+	filterSmudge = "smudge"
+)
+
+// filterDriver is a content filter configured as filter.<name>:
+//
+//	[filter "name"]
+//		clean = <command run when a file is staged>
+//		smudge = <command run when a file is checked out>
+//		process = <long-running command serving both>
+//		required = true
+//
+// clean and smudge read the content on standard input and write the
+// converted one on standard output; "%f" in them is replaced with the path
+// of the file. process is started once and serves every file over its
+// standard input and output with the long-running filter protocol of Git,
+// so that existing filters work unchanged; it takes precedence over clean
+// and smudge.
+//
+// When a filter fails the content is used unconverted, with a warning,
+// unless the driver is required.
+type filterDriver struct {
+	name     string
+	clean    string
+	smudge   string
+	process  string
+	required bool
+}
+
+// loadFilterDriver reads the configuration of a filter driver. It returns
+// nil when nothing is configured for name.
+func loadFilterDriver(name string) (*filterDriver, error) {
+	d := &filterDriver{name: name}
+	configured := false
+	for key, value := range map[string]*string{"clean": &d.clean, "smudge": &d.smudge, "process": &d.process} {
+		v, isSet, err := ConfigValue(fmt.Sprintf("filter.%s.%s", name, key))
+		if err != nil {
+			return nil, err
+		}
+		*value = v
+		configured = configured || isSet
+	}
+
+	required, isSet, err := ConfigValue(fmt.Sprintf("filter.%s.required", name))
+	if err != nil {
+		return nil, err
+	}
+	if isSet {
+		if d.required, err = strconv.ParseBool(required); err != nil {
+			return nil, fmt.Errorf("fatal: bad boolean config value '%s' for 'filter.%s.required'", required, name)
+		}
+		configured = true
+	}
+
+	if !configured {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// apply runs the clean or smudge command of the driver on the content of
+// filePath.
+func (d *filterDriver) apply(command, filePath string, content []byte) ([]byte, error) {
+	var converted []byte
+	var err error
+	switch {
+	case d.process != "":
+		converted, err = runFilterProcess(d.process, command, filePath, content)
+	case command == filterClean && d.clean != "":
+		converted, err = runFilterCommand(d.clean, filePath, content)
+	case command == filterSmudge && d.smudge != "":
+		converted, err = runFilterCommand(d.smudge, filePath, content)
+	case d.required:
+		return nil, fmt.Errorf("fatal: %s: %s filter '%s' is required but not configured", filePath, command, d.name)
+	default:
+		return content, nil
+	}
+
+	if err != nil {
+		if d.required {
+			return nil, fmt.Errorf("fatal: %s: %s filter '%s' failed: %w", filePath, command, d.name, err)
+		}
+		fmt.Fprintf(os.Stderr, "error: %s: %s filter '%s' failed: %v\n", filePath, command, d.name, err)
+		return content, nil
+	}
+	return converted, nil
+}
+
+// runFilterCommand runs a clean or smudge command through the shell.
+func runFilterCommand(command, filePath string, content []byte) ([]byte, error) {
+	command = strings.ReplaceAll(command, "%f", shellQuote(filePath))
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	os.Stderr.Write(stderr.Bytes())
+	return stdout.Bytes(), nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// filterProcess is a running long-running filter. Requests are served one
+// at a time.
+type filterProcess struct {
+	mu           sync.Mutex
+	cmd          *exec.Cmd
+	in           io.WriteCloser
+	out          *bufio.Reader
+	capabilities map[string]bool
+	err          error // why the process cannot be used any more
+}
+
+var (
+	filterProcessesMu sync.Mutex
+	filterProcesses   = make(map[string]*filterProcess) // by command line
+)
+
+// runFilterProcess sends a clean or smudge request to the process started
+// for command, starting it the first time.
+func runFilterProcess(command, request, filePath string, content []byte) ([]byte, error) {
+	filterProcessesMu.Lock()
+	p, started := filterProcesses[command]
+	if !started {
+		p = startFilterProcess(command)
+		filterProcesses[command] = p
+	}
+	filterProcessesMu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if !p.capabilities[request] {
+		return content, nil
+	}
+
+	converted, err := p.request(request, filePath, content)
+	var status filterStatusError
+	if err != nil && !errors.As(err, &status) {
+		// The conversation is broken: the process is not used again.
+		p.err = fmt.Errorf("filter process '%s' failed: %w", command, err)
+		return nil, p.err
+	}
+	if status == "abort" {
+		p.capabilities[request] = false
+	}
+	return converted, err
+}
+
+// startFilterProcess starts a long-running filter and negotiates the
+// version and capabilities of the protocol. A process that could not be
+// started is returned with its error.
+func startFilterProcess(command string) *filterProcess {
+	p := &filterProcess{capabilities: make(map[string]bool)}
+	p.cmd = exec.Command("sh", "-c", command)
+	p.cmd.Stderr = os.Stderr
+
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	if err := p.cmd.Start(); err != nil {
+		p.err = fmt.Errorf("cannot start filter process '%s': %w", command, err)
+		return p
+	}
+	p.in, p.out = stdin, bufio.NewReader(stdout)
+
+	if err := p.handshake(); err != nil {
+		p.err = fmt.Errorf("filter process '%s' failed the handshake: %w", command, err)
+		p.stop()
+	}
+	return p
+}
+
+// handshake exchanges the welcome messages and the capabilities.
+func (p *filterProcess) handshake() error {
+	if err := writePackets(p.in, "git-filter-client", "version=2"); err != nil {
+		return err
+	}
+	welcome, err := readPacketList(p.out)
+	if err != nil {
+		return err
+	}
+	if len(welcome) != 2 || welcome[0] != "git-filter-server" || welcome[1] != "version=2" {
+		return fmt.Errorf("unexpected welcome %q", welcome)
+	}
+
+	if err := writePackets(p.in, "capability=clean", "capability=smudge"); err != nil {
+		return err
+	}
+	capabilities, err := readPacketList(p.out)
+	if err != nil {
+		return err
+	}
+	for _, capability := range capabilities {
+		if name, found := strings.CutPrefix(capability, "capability="); found {
+			p.capabilities[name] = true
+		}
+	}
+	return nil
+}
+
+// filterStatusError is a request the process answered with a status other
+// than "success".
+type filterStatusError string
+
+func (s filterStatusError) Error() string {
+	return "status=" + string(s)
+}
+
+// request sends one file to the process and returns the converted content.
+func (p *filterProcess) request(request, filePath string, content []byte) ([]byte, error) {
+	if err := writePackets(p.in, "command="+request, "pathname="+filePath); err != nil {
+		return nil, err
+	}
+	if err := writePacketContent(p.in, content); err != nil {
+		return nil, err
+	}
+
+	status, err := readFilterStatus(p.out, "")
+	if err != nil {
+		return nil, err
+	}
+	if status != "success" {
+		return nil, filterStatusError(status)
+	}
+
+	converted, err := readPacketContent(p.out)
+	if err != nil {
+		return nil, err
+	}
+	// The status may be changed after the content, an empty list keeping it.
+	if status, err = readFilterStatus(p.out, status); err != nil {
+		return nil, err
+	}
+	if status != "success" {
+		return nil, filterStatusError(status)
+	}
+	return converted, nil
+}
+
+// readFilterStatus reads a list of packets holding a status, returning
+// current when the list has none.
+func readFilterStatus(r *bufio.Reader, current string) (string, error) {
+	list, err := readPacketList(r)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range list {
+		if status, found := strings.CutPrefix(line, "status="); found {
+			current = status
+		}
+	}
+	if current == "" {
+		return "", fmt.Errorf("no status in the response")
+	}
+	return current, nil
+}
+
+// stop closes the input of the process, which tells it to exit, and waits
+// for it.
+func (p *filterProcess) stop() {
+	if p.in == nil {
+		return
+	}
+	p.in.Close()
+	p.cmd.Wait()
+	p.in = nil
+	if p.err == nil {
+		p.err = fmt.Errorf("filter process stopped")
+	}
+}
+
+// StopFilterProcesses stops the long-running filters started by the
+// command, letting them finish their work.
+func StopFilterProcesses() {
+	filterProcessesMu.Lock()
+	defer filterProcessesMu.Unlock()
+
+	for command, p := range filterProcesses {
+		p.mu.Lock()
+		p.stop()
+		p.mu.Unlock()
+		delete(filterProcesses, command)
+	}
+}
+
+// The filter protocol is made of pkt-lines: four hexadecimal digits giving
+// the length of the packet, header included, then the data. "0000" is a
+// flush packet that ends a list or a content.
+const maxPacketData = 65516
+
+// writePackets writes text packets, each ending with a newline, then a
+// flush packet.
+func writePackets(w io.Writer, lines ...string) error {
+	for _, line := range lines {
+		if err := writePacket(w, []byte(line+"\n")); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "0000")
+	return err
+}
+
+// writePacketContent writes content in as many packets as needed, then a
+// flush packet.
+func writePacketContent(w io.Writer, content []byte) error {
+	for len(content) > 0 {
+		n := min(len(content), maxPacketData)
+		if err := writePacket(w, content[:n]); err != nil {
+			return err
+		}
+		content = content[n:]
+	}
+	_, err := io.WriteString(w, "0000")
+	return err
+}
+
+func writePacket(w io.Writer, data []byte) error {
+	if _, err := fmt.Fprintf(w, "%04x", len(data)+4); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readPacket reads one packet; a flush packet returns nil data.
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad packet header %q", header)
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length <= 4 {
+		return nil, fmt.Errorf("bad packet length %d", length)
+	}
+
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readPacketList reads text packets up to a flush packet.
+func readPacketList(r *bufio.Reader) ([]string, error) {
+	var lines []string
+	for {
+		data, err := readPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimSuffix(string(data), "\n"))
+	}
+}
+
+// readPacketContent reads binary packets up to a flush packet.
+func readPacketContent(r *bufio.Reader) ([]byte, error) {
+	var content bytes.Buffer
+	for {
+		data, err := readPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return content.Bytes(), nil
+		}
+		content.Write(data)
+	}
+}
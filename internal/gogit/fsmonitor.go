@@ -0,0 +1,508 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The filesystem monitor is a daemon watching the working tree, so that
+// status and add only look at the paths changed since their previous run
+// instead of reading every file.
+//
+// Clients talk to it over a Unix socket (.gogit/fsmonitor--daemon.ipc). A
+// request is a single line; the answer is a list of NUL-terminated fields:
+//
+//	query <token>   <new token> followed by the paths changed since <token>,
+//	                or by the single path "/" when the daemon cannot tell
+//	                (unknown token, event queue overflow) and everything must
+//	                be looked at
+//	ping            <current token>
+//	stop            "ok", and the daemon exits
+//
+// Tokens have the form "<daemon id>:<sequence number>", so a token handed
+// out by an earlier daemon never matches.
+
+const (
+	// fsmonitorTrivial is the answer meaning "anything may have changed".
+	fsmonitorTrivial = "/"
+	// fsmonitorTimeout bounds a request to the daemon.
+	fsmonitorTimeout = 10 * time.Second
+	// fsmonitorStartTimeout is how long start waits for the daemon to answer.
+	fsmonitorStartTimeout = 5 * time.Second
+)
+
+// monitorState records the paths changed since the daemon started.
+type monitorState struct {
+	mu      sync.Mutex
+	id      string            // identifies this daemon run in the tokens
+	seq     uint64            // sequence number of the last change
+	since   uint64            // tokens older than this get a trivial answer
+	changed map[string]uint64 // path -> sequence number of its last change
+}
+
+func newMonitorState() *monitorState {
+	return &monitorState{
+		id:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		changed: make(map[string]uint64),
+	}
+}
+
+// record notes a change of filePath (relative to the repository root).
+func (s *monitorState) record(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	s.changed[filePath] = s.seq
+}
+
+// reset forgets every change, after events were lost: the clients holding an
+// older token have to look at the whole tree.
+func (s *monitorState) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	s.since = s.seq
+	s.changed = make(map[string]uint64)
+}
+
+// token returns the token describing the current state.
+func (s *monitorState) token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fmt.Sprintf("%s:%d", s.id, s.seq)
+}
+
+// changesSince returns the current token and the paths changed after token,
+// or trivial when they are unknown.
+func (s *monitorState) changesSince(token string) (current string, paths []string, trivial bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current = fmt.Sprintf("%s:%d", s.id, s.seq)
+	id, seqText, found := strings.Cut(token, ":")
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if !found || err != nil || id != s.id || seq < s.since || seq > s.seq {
+		return current, nil, true
+	}
+
+	for filePath, changedAt := range s.changed {
+		if changedAt > seq {
+			paths = append(paths, filePath)
+		}
+	}
+	sort.Strings(paths)
+	return current, paths, false
+}
+
+// RunFsmonitorDaemon watches the working tree and answers the requests of
+// the clients until it is stopped.
+func RunFsmonitorDaemon() error {
+	if _, err := fsmonitorRequest("ping"); err == nil {
+		return fmt.Errorf("fatal: fsmonitor--daemon is already running in '%s'", worktreeRoot())
+	}
+
+	state := newMonitorState()
+	// The watches are in place before the first token is handed out, so no
+	// change can be missed by a client.
+	watcher, err := newWorktreeWatcher(state)
+	if err != nil {
+		return err
+	}
+
+	// A socket left behind by a daemon that crashed is in the way.
+	os.Remove(FsmonitorPath)
+	listener, err := net.Listen("unix", FsmonitorPath)
+	if err != nil {
+		return fmt.Errorf("fatal: could not listen on %s: %w", FsmonitorPath, err)
+	}
+	defer os.Remove(FsmonitorPath)
+	defer listener.Close()
+
+	done := make(chan error, 1)
+	var stopOnce sync.Once
+	stop := func(err error) {
+		stopOnce.Do(func() { done <- err })
+	}
+
+	go func() {
+		stop(watcher.run())
+	}()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		stop(nil)
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				stop(fmt.Errorf("error accepting a connection: %w", err))
+				return
+			}
+			go serveFsmonitorClient(conn, state, stop)
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "fsmonitor-daemon is watching '%s'\n", worktreeRoot())
+	return <-done
+}
+
+// serveFsmonitorClient answers a single request.
+func serveFsmonitorClient(conn net.Conn, state *monitorState, stop func(error)) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(fsmonitorTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	command, argument, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+	var fields []string
+	switch command {
+	case "query":
+		current, paths, trivial := state.changesSince(argument)
+		fields = append(fields, current)
+		if trivial {
+			fields = append(fields, fsmonitorTrivial)
+		} else {
+			fields = append(fields, paths...)
+		}
+	case "ping":
+		fields = append(fields, state.token())
+	case "stop":
+		fields = append(fields, "ok")
+		defer stop(nil)
+	default:
+		fields = append(fields, "error: unknown command "+command)
+	}
+
+	var buf bytes.Buffer
+	for _, field := range fields {
+		buf.WriteString(field)
+		buf.WriteByte(0)
+	}
+	conn.Write(buf.Bytes())
+}
+
+// fsmonitorRequest sends a request to the daemon and returns the fields of
+// its answer. It fails when no daemon is running.
+func fsmonitorRequest(request string) ([]string, error) {
+	conn, err := net.DialTimeout("unix", FsmonitorPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(fsmonitorTimeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\n", request); err != nil {
+		return nil, err
+	}
+	answer, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) == 0 {
+		return nil, fmt.Errorf("empty answer from fsmonitor--daemon")
+	}
+	return strings.Split(strings.TrimSuffix(string(answer), "\x00"), "\x00"), nil
+}
+
+// StartFsmonitorDaemon runs the daemon in the background and waits until it
+// answers.
+func StartFsmonitorDaemon() error {
+	if _, err := fsmonitorRequest("ping"); err == nil {
+		return fmt.Errorf("fatal: fsmonitor--daemon is already running in '%s'", worktreeRoot())
+	}
+	if err := fsmonitorSupported(); err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("fatal: cannot find the gogit executable: %w", err)
+	}
+	logFile, err := os.Create(FsmonitorLogPath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", FsmonitorLogPath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, "fsmonitor--daemon", "run")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = daemonSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("fatal: could not start fsmonitor--daemon: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	deadline := time.Now().Add(fsmonitorStartTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := fsmonitorRequest("ping"); err == nil {
+			fmt.Printf("fsmonitor-daemon is watching '%s'\n", worktreeRoot())
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("fatal: fsmonitor--daemon failed to start (see %s)", FsmonitorLogPath)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("fatal: fsmonitor--daemon did not answer in time (see %s)", FsmonitorLogPath)
+}
+
+// StopFsmonitorDaemon asks the daemon to exit and waits until it is gone.
+func StopFsmonitorDaemon() error {
+	if _, err := fsmonitorRequest("stop"); err != nil {
+		return fmt.Errorf("fatal: fsmonitor--daemon is not running in '%s'", worktreeRoot())
+	}
+
+	deadline := time.Now().Add(fsmonitorStartTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := fsmonitorRequest("ping"); err != nil {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("fatal: fsmonitor--daemon did not stop in time")
+}
+
+// FsmonitorDaemonStatus prints whether the daemon is watching the working
+// tree, and returns it.
+func FsmonitorDaemonStatus() bool {
+	if _, err := fsmonitorRequest("ping"); err != nil {
+		fmt.Printf("fsmonitor-daemon is not watching '%s'\n", worktreeRoot())
+		return false
+	}
+	fmt.Printf("fsmonitor-daemon is watching '%s'\n", worktreeRoot())
+	return true
+}
+
+// worktreeRoot returns the absolute path of the working tree, for messages.
+func worktreeRoot() string {
+	root, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return root
+}
+
+// fsmonitorEnabled reports whether core.fsmonitor is set to true.
+func fsmonitorEnabled() (bool, error) {
+	value, isSet, err := ConfigValue("core.fsmonitor")
+	if err != nil || !isSet {
+		return false, err
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("fatal: bad boolean config value '%s' for 'core.fsmonitor'", value)
+	}
+	return enabled, nil
+}
+
+// monitorCache is the working tree map built by the previous run, with the
+// daemon token it is valid for.
+type monitorCache struct {
+	token string
+	// stamp describes the ignore and attribute files the daemon does not
+	// watch; when it changes the whole tree is walked again.
+	stamp   string
+	entries map[string]TreeEntry
+}
+
+// monitoredWorkdirMap returns the working tree map using the daemon, or
+// false when core.fsmonitor is off or the daemon is not running, in which
+// case the caller walks the whole tree.
+func monitoredWorkdirMap(filter *worktreeFilter, indexMap map[string]IndexEntry) (map[string]TreeEntry, bool, error) {
+	enabled, err := fsmonitorEnabled()
+	if err != nil || !enabled {
+		return nil, false, err
+	}
+
+	cache := readMonitorCache()
+	token := ""
+	if cache != nil {
+		token = cache.token
+	}
+	answer, err := fsmonitorRequest("query " + token)
+	if err != nil {
+		return nil, false, nil
+	}
+	current, changed := answer[0], answer[1:]
+
+	stamp := ruleStamp()
+	var workdirMap map[string]TreeEntry
+	if cache == nil || cache.stamp != stamp || slices.Contains(changed, fsmonitorTrivial) || touchesRuleFile(changed) {
+		workdirMap, err = walkWorkdir(filter, []string{"."})
+	} else {
+		workdirMap, err = refreshWorkdirMap(filter, indexMap, cache.entries, changed)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The cache only saves time: failing to write it is not an error.
+	writeMonitorCache(&monitorCache{token: current, stamp: stamp, entries: workdirMap})
+	return workdirMap, true, nil
+}
+
+// refreshWorkdirMap updates the map of the previous run with the changed
+// paths reported by the daemon.
+func refreshWorkdirMap(filter *worktreeFilter, indexMap map[string]IndexEntry, entries map[string]TreeEntry, changed []string) (map[string]TreeEntry, error) {
+	changedSet := make(map[string]bool, len(changed))
+	for _, filePath := range changed {
+		changedSet[filePath] = true
+	}
+
+	// Forget the changed paths and everything below them (a changed
+	// directory may have been removed or renamed).
+	for filePath := range entries {
+		for p := filePath; p != "."; p = path.Dir(p) {
+			if changedSet[p] {
+				delete(entries, filePath)
+				break
+			}
+		}
+	}
+
+	// Read them again, as well as the tracked files missing from the map.
+	var roots []string
+	for _, filePath := range changed {
+		if _, err := os.Lstat(filepath.FromSlash(filePath)); err == nil {
+			roots = append(roots, filePath)
+		}
+	}
+	for filePath := range indexMap {
+		if _, known := entries[filePath]; !known && !changedSet[filePath] {
+			if _, err := os.Lstat(filepath.FromSlash(filePath)); err == nil {
+				roots = append(roots, filePath)
+			}
+		}
+	}
+	scanned, err := walkWorkdir(filter, roots)
+	if err != nil {
+		return nil, err
+	}
+	for filePath, entry := range scanned {
+		entries[filePath] = entry
+	}
+
+	// Untracked files may have become ignored, or files stopped being
+	// tracked, since the previous run.
+	for filePath := range entries {
+		if filter.tracked[filePath] {
+			continue
+		}
+		skip, err := filter.skip(filePath, false)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			delete(entries, filePath)
+		}
+	}
+	return entries, nil
+}
+
+// touchesRuleFile reports whether a .gogitignore or .gogitattributes file is
+// among the changed paths, which changes what is ignored, or how files are
+// hashed, anywhere below it.
+func touchesRuleFile(changed []string) bool {
+	for _, filePath := range changed {
+		if name := path.Base(filePath); name == ignoreFileName || name == attributesFileName {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleStamp describes the ignore and attribute files outside the working
+// tree, and the configuration files: filters and core.eol change the hash
+// of the files too.
+func ruleStamp() string {
+	files := []string{ExcludePath, AttributesPath, RepoConfigPath, os.ExpandEnv("$HOME/" + GLOBAL_CONFIG)}
+	if globalPath, err := globalExcludesFile(); err == nil && globalPath != "" {
+		files = append(files, globalPath)
+	}
+	if globalPath, err := userConfigFile("core.attributesFile", "attributes"); err == nil && globalPath != "" {
+		files = append(files, globalPath)
+	}
+
+	var stamp []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			stamp = append(stamp, file+":-")
+			continue
+		}
+		stamp = append(stamp, fmt.Sprintf("%s:%d:%d", file, info.Size(), info.ModTime().UnixNano()))
+	}
+	return strings.Join(stamp, " ")
+}
+
+// readMonitorCache reads the cache of the previous run, or returns nil when
+// there is none or it cannot be used.
+func readMonitorCache() *monitorCache {
+	content, err := os.ReadFile(FsmonitorCache)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	cache := &monitorCache{token: lines[0], stamp: lines[1], entries: make(map[string]TreeEntry, len(lines)-2)}
+	for _, line := range lines[2:] {
+		meta, filePath, found := strings.Cut(line, "\t")
+		mode, hash, hasHash := strings.Cut(meta, " ")
+		if !found || !hasHash {
+			return nil
+		}
+		cache.entries[filePath] = TreeEntry{Mode: mode, Hash: hash}
+	}
+	return cache
+}
+
+// writeMonitorCache saves the map for the next run; "<token>\n<stamp>\n"
+// followed by a "<mode> <hash>\t<path>" line per file.
+func writeMonitorCache(cache *monitorCache) {
+	paths := make([]string, 0, len(cache.entries))
+	for filePath := range cache.entries {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%s\n", cache.token, cache.stamp)
+	for _, filePath := range paths {
+		entry := cache.entries[filePath]
+		fmt.Fprintf(&buf, "%s %s\t%s\n", entry.Mode, entry.Hash, filePath)
+	}
+
+	_ = writeLockedFile(FsmonitorCache, buf.Bytes())
+}
@@ -0,0 +1,164 @@
+//go:build linux
+
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watchMask selects the inotify events that change the working tree.
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW | syscall.IN_EXCL_UNLINK
+
+// worktreeWatcher records the changes of the working tree with inotify. A
+// watch is added on every directory, as inotify is not recursive.
+type worktreeWatcher struct {
+	fd    int
+	state *monitorState
+	dirs  map[int32]string // watch descriptor -> directory, "" for the root
+}
+
+// fsmonitorSupported reports whether the daemon can run here.
+func fsmonitorSupported() error {
+	return nil
+}
+
+// daemonSysProcAttr detaches the daemon from the terminal that started it.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// newWorktreeWatcher watches the whole working tree.
+func newWorktreeWatcher(state *monitorState) (*worktreeWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("fatal: could not initialize inotify: %w", err)
+	}
+
+	w := &worktreeWatcher{fd: fd, state: state, dirs: make(map[int32]string)}
+	if err := w.watchTree(""); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return w, nil
+}
+
+// watchTree adds a watch on dir ("" for the root) and every directory below
+// it, except the repositories themselves. Watching an already watched
+// directory returns its descriptor, which maps it to its new name after a
+// rename.
+func (w *worktreeWatcher) watchTree(dir string) error {
+	root := "."
+	if dir != "" {
+		root = filepath.FromSlash(dir)
+	}
+
+	return filepath.WalkDir(root, func(osPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ROOT || d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, osPath, watchMask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+				return filepath.SkipDir
+			}
+			if errors.Is(err, syscall.ENOSPC) {
+				return fmt.Errorf("fatal: too many directories to watch; raise fs.inotify.max_user_watches")
+			}
+			return fmt.Errorf("fatal: could not watch %s: %w", osPath, err)
+		}
+
+		relativePath := filepath.ToSlash(filepath.Clean(osPath))
+		if relativePath == "." {
+			relativePath = ""
+		}
+		w.dirs[int32(wd)] = relativePath
+		return nil
+	})
+}
+
+// run reads the events until an error occurs.
+func (w *worktreeWatcher) run() error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return fmt.Errorf("error reading inotify events: %w", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			name := string(buf[nameStart:nameEnd])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			offset = nameEnd
+
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+// handle records one event.
+func (w *worktreeWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Events were lost: nothing is known any more.
+		w.state.reset()
+		return
+	}
+
+	dir, known := w.dirs[wd]
+	if !known {
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return
+	}
+	if name == "" {
+		// The watched directory itself was removed or renamed.
+		if dir != "" {
+			w.state.record(dir)
+		}
+		return
+	}
+	if name == ROOT || name == ".git" {
+		return
+	}
+
+	changedPath := path.Join(dir, name)
+	w.state.record(changedPath)
+
+	// New directories are watched too; the files created in them before
+	// the watch was added are covered by the change of the directory.
+	if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if err := w.watchTree(changedPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			w.state.reset()
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package gogit
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// worktreeWatcher is only implemented with inotify.
+type worktreeWatcher struct{}
+
+// fsmonitorSupported reports whether the daemon can run here.
+func fsmonitorSupported() error {
+	return fmt.Errorf("fatal: fsmonitor--daemon is not supported on this platform")
+}
+
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func newWorktreeWatcher(_ *monitorState) (*worktreeWatcher, error) {
+	return nil, fsmonitorSupported()
+}
+
+func (w *worktreeWatcher) run() error {
+	return fsmonitorSupported()
+}
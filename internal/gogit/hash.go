@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"time"
 )
@@ -25,7 +27,27 @@ func HashObject(content []byte) (string, bytes.Buffer, error) {
 	return blobHash, buffer, nil
 }
 
-func HashTree(files map[string]string) (string, []byte, error) {
+// writeBlob stores content as a blob object and returns its hash.
+func writeBlob(content []byte) (string, error) {
+	blobHash, buffer, err := HashObject(content)
+	if err != nil {
+		return "", err
+	}
+
+	objectPath := filepath.Join(ObjectsPath, blobHash[:2], blobHash[2:])
+	if _, err := os.Stat(objectPath); err == nil {
+		return blobHash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory for object %s: %w", blobHash, err)
+	}
+	if err := os.WriteFile(objectPath, buffer.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("error writing object %s: %w", blobHash, err)
+	}
+	return blobHash, nil
+}
+
+func HashTree(files map[string]TreeEntry) (string, []byte, error) {
 	var contentBuffer bytes.Buffer
 
 	// To ensure a deterministic tree hash, we must sort the files by their path.
@@ -36,11 +58,11 @@ func HashTree(files map[string]string) (string, []byte, error) {
 	sort.Strings(paths) // Sort alphabetically.
 
 	for _, path := range paths {
-		hash := files[path]
+		entry := files[path]
 		// Format: <mode> <type> <hash>\t<path>
-		// For simplicity, we'll use a generic mode and type for now.
-		// Real Git uses 100644 for files, 040000 for trees.
-		fmt.Fprintf(&contentBuffer, "100644 blob %s\t%s\n", hash, path)
+		// The tree is flat, so every entry is a blob: 100644 for regular files,
+		// 100755 for executables and 120000 for symlinks.
+		fmt.Fprintf(&contentBuffer, "%s blob %s\t%s\n", entry.Mode, entry.Hash, path)
 	}
 
 	treeContent := contentBuffer.Bytes()
@@ -0,0 +1,74 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HeadState describes what HEAD points at.
+type HeadState struct {
+	// Branch is the current branch, empty when HEAD is detached.
+	Branch string
+	// Hash is the commit HEAD points at, empty on a branch without commits.
+	Hash string
+}
+
+// Detached reports whether HEAD points directly at a commit.
+func (h HeadState) Detached() bool {
+	return h.Branch == ""
+}
+
+// ReadHead reads HEAD, which holds either "ref: refs/heads/<branch>" or,
+// when detached, a commit hash.
+func ReadHead() (HeadState, error) {
+	return readHeadFile(HeadPath)
+}
+
+// readHeadFile reads the HEAD of a working tree.
+func readHeadFile(headPath string) (HeadState, error) {
+	content, err := os.ReadFile(headPath)
+	if err != nil {
+		return HeadState{}, fmt.Errorf("error reading HEAD: %w", err)
+	}
+
+	line := strings.TrimSpace(string(content))
+	ref, isSymbolic := strings.CutPrefix(line, "ref:")
+	if !isSymbolic {
+		return HeadState{Hash: line}, nil
+	}
+
+	ref = strings.TrimSpace(ref)
+	hash, err := readRef(filepath.Join(CommonPath, ref))
+	if err != nil {
+		return HeadState{}, err
+	}
+	return HeadState{Branch: strings.TrimPrefix(ref, "refs/heads/"), Hash: hash}, nil
+}
+
+// headTargetPath returns the file that moves when a commit is made: the ref
+// of the current branch, or HEAD itself when detached.
+func headTargetPath() (string, error) {
+	head, err := ReadHead()
+	if err != nil {
+		return "", err
+	}
+	if head.Detached() {
+		return HeadPath, nil
+	}
+	return filepath.Join(RefHeadsPath, head.Branch), nil
+}
+
+// readRef returns the hash stored in a ref file, or an empty string when the
+// ref does not exist yet.
+func readRef(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
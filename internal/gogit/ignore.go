@@ -2,50 +2,357 @@ package gogit
 
 import (
 	"bufio"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
-// readGogitignore reads the .gogitignore file and returns a list of patterns.
-func readGogitignore() ([]string, error) {
-	ignorePath := filepath.Join(".", ".gogitignore")
-	file, err := os.Open(ignorePath)
+// ignoreFileName is the name of the per-directory ignore files.
+const ignoreFileName = ".gogitignore"
+
+// ignoreRule is one pattern of an ignore file.
+type ignoreRule struct {
+	pattern  string // wildmatch pattern, without "!", leading and trailing "/"
+	base     string // directory of the file holding the rule, "" for the root
+	negated  bool   // "!pattern" re-includes what earlier rules excluded
+	dirOnly  bool   // "pattern/" only matches directories
+	anchored bool   // a "/" in the pattern: matched against the path relative to base
+
+	source string // file the rule comes from, for check-ignore
+	line   int
+	text   string // the pattern as written
+}
+
+// IgnoreMatcher decides which paths are ignored, following the rules of
+// gitignore. Patterns are read from, in increasing order of precedence:
+//
+//   - the file named by core.excludesFile (by default ~/.config/gogit/ignore)
+//   - .gogit/info/exclude
+//   - the .gogitignore file of every directory, deeper files winning over
+//     the ones of their parents
+//
+// Within a file the last matching pattern wins, "!" re-includes a path, a
+// trailing "/" only matches directories, a "/" elsewhere anchors the pattern
+// to the directory of the file, and "**" matches any number of directories.
+// As in Git, a file cannot be re-included when one of its parent
+// directories is excluded.
+//
+// The per-directory files are read on demand, so a matcher must be created
+// after the working tree is set up and is safe for concurrent use.
+type IgnoreMatcher struct {
+	cmdline []ignoreRule // patterns given on the command line, which win over all files
+	global  []ignoreRule
+	exclude []ignoreRule
+
+	mu     sync.Mutex
+	perDir map[string][]ignoreRule // directory -> rules of its .gogitignore, nil when unused
+}
+
+// NewIgnoreMatcher loads the global and repository-wide exclude files.
+func NewIgnoreMatcher() (*IgnoreMatcher, error) {
+	m := &IgnoreMatcher{perDir: make(map[string][]ignoreRule)}
+
+	globalPath, err := globalExcludesFile()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // .gogitignore not found, return no patterns
+		return nil, err
+	}
+	if globalPath != "" {
+		if m.global, err = readIgnoreFile(globalPath, globalPath, ""); err != nil {
+			return nil, err
 		}
+	}
+
+	excludeSource := filepath.ToSlash(filepath.Join(ROOT, "info", "exclude"))
+	if m.exclude, err = readIgnoreFile(ExcludePath, excludeSource, ""); err != nil {
 		return nil, err
 	}
+
+	return m, nil
+}
+
+// newPatternMatcher returns a matcher using only patterns given on the
+// command line, for commands told to disregard the standard ignore rules.
+func newPatternMatcher(patterns []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	m.addPatterns(patterns)
+	return m
+}
+
+// addPatterns adds patterns given on the command line, with the highest
+// precedence.
+func (m *IgnoreMatcher) addPatterns(patterns []string) {
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreLine(pattern); ok {
+			m.cmdline = append(m.cmdline, rule)
+		}
+	}
+}
+
+// globalExcludesFile returns the path configured with core.excludesFile, or
+// the default ~/.config/gogit/ignore ($XDG_CONFIG_HOME/gogit/ignore).
+func globalExcludesFile() (string, error) {
+	return userConfigFile("core.excludesFile", "ignore")
+}
+
+// userConfigFile returns the path set by the variable, with "~/" expanded,
+// or the default $XDG_CONFIG_HOME/gogit/<name> (~/.config/gogit/<name>).
+func userConfigFile(variable, name string) (string, error) {
+	value, isSet, err := ConfigValue(variable)
+	if err != nil {
+		return "", err
+	}
+	if isSet {
+		if rest, found := strings.CutPrefix(value, "~/"); found {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("cannot expand %s: %w", value, err)
+			}
+			value = filepath.Join(home, rest)
+		}
+		return value, nil
+	}
+
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "gogit", name), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil
+	}
+	return filepath.Join(home, ".config", "gogit", name), nil
+}
+
+// readIgnoreFile parses an ignore file. A missing file has no rules.
+func readIgnoreFile(filePath, source, base string) ([]ignoreRule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", source, err)
+	}
 	defer file.Close()
 
-	var patterns []string
+	var rules []ignoreRule
 	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			patterns = append(patterns, line)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rule.base = base
+			rule.source = source
+			rule.line = lineNumber
+			rules = append(rules, rule)
 		}
 	}
-
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error reading %s: %w", source, err)
 	}
+	return rules, nil
+}
 
-	return patterns, nil
+// parseIgnoreLine parses one line of an ignore file. It returns false for
+// blank lines and comments.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless escaped with a backslash.
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' && !(end >= 2 && line[end-2] == '\\') {
+		end--
+	}
+	pattern := line[:end]
+	rule := ignoreRule{text: pattern}
+
+	if pattern == "" || pattern[0] == '#' {
+		return ignoreRule{}, false
+	}
+	if pattern[0] == '!' {
+		rule.negated = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = pattern
+	return rule, true
 }
 
-// isIgnored checks if a given path should be ignored based on the patterns
-// from .gogitignore.
-func isIgnored(path string, patterns []string) (bool, error) {
-	for _, pattern := range patterns {
-		matched, err := filepath.Match(pattern, path)
+// matches reports whether the rule selects filePath (relative to the
+// repository root).
+func (r *ignoreRule) matches(filePath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	relative := filePath
+	if r.base != "" {
+		if !strings.HasPrefix(filePath, r.base+"/") {
+			return false
+		}
+		relative = filePath[len(r.base)+1:]
+	}
+
+	if r.anchored {
+		return wildmatch(r.pattern, relative, true)
+	}
+	return wildmatch(r.pattern, path.Base(relative), true)
+}
+
+// IsIgnored reports whether filePath (relative to the repository root, with
+// forward slashes) is ignored.
+func (m *IgnoreMatcher) IsIgnored(filePath string, isDir bool) (bool, error) {
+	rule, err := m.Match(filePath, isDir)
+	if err != nil {
+		return false, err
+	}
+	return rule != nil && !rule.negated, nil
+}
+
+// Match returns the rule that decides whether filePath is ignored, or nil
+// when no rule applies. A negated rule means the path is explicitly not
+// ignored. When a parent directory is excluded, its rule is returned.
+func (m *IgnoreMatcher) Match(filePath string, isDir bool) (*ignoreRule, error) {
+	filePath = strings.Trim(filepath.ToSlash(filePath), "/")
+
+	// An excluded directory hides everything inside it.
+	for i := 0; i < len(filePath); i++ {
+		if filePath[i] != '/' {
+			continue
+		}
+		rule, err := m.matchOne(filePath[:i], true)
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil && !rule.negated {
+			return rule, nil
+		}
+	}
+
+	return m.matchOne(filePath, isDir)
+}
+
+// matchOne applies the rules to a single path, ignoring its parents.
+func (m *IgnoreMatcher) matchOne(filePath string, isDir bool) (*ignoreRule, error) {
+	if rule := lastMatch(m.cmdline, filePath, isDir); rule != nil {
+		return rule, nil
+	}
+
+	// Deeper .gogitignore files first, then the root one.
+	dir := path.Dir(filePath)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		rules, err := m.dirRules(dir)
 		if err != nil {
-			return false, err
+			return nil, err
+		}
+		if rule := lastMatch(rules, filePath, isDir); rule != nil {
+			return rule, nil
 		}
-		if matched {
-			return true, nil
+		if dir == "" {
+			break
 		}
+		dir = path.Dir(dir)
+	}
+
+	if rule := lastMatch(m.exclude, filePath, isDir); rule != nil {
+		return rule, nil
+	}
+	return lastMatch(m.global, filePath, isDir), nil
+}
+
+// lastMatch returns the last rule of the list matching filePath.
+func lastMatch(rules []ignoreRule, filePath string, isDir bool) *ignoreRule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(filePath, isDir) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// dirRules returns the rules of the .gogitignore file in dir ("" for the
+// root), reading it the first time.
+func (m *IgnoreMatcher) dirRules(dir string) ([]ignoreRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.perDir == nil {
+		return nil, nil
+	}
+	if rules, loaded := m.perDir[dir]; loaded {
+		return rules, nil
+	}
+
+	source := path.Join(dir, ignoreFileName)
+	rules, err := readIgnoreFile(filepath.FromSlash(source), source, dir)
+	if err != nil {
+		return nil, err
+	}
+	m.perDir[dir] = rules
+	return rules, nil
+}
+
+// worktreeFilter tells the walks of the working tree which paths to leave
+// out: the repository itself and the ignored paths. Tracked files are never
+// ignored, and neither are the directories holding them.
+type worktreeFilter struct {
+	ignore  *IgnoreMatcher
+	tracked map[string]bool // tracked files and their parent directories
+}
+
+// newWorktreeFilter builds a filter for the given index.
+func newWorktreeFilter(index map[string]IndexEntry) (*worktreeFilter, error) {
+	ignore, err := NewIgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &worktreeFilter{ignore: ignore, tracked: trackedPaths(index)}, nil
+}
+
+// trackedPaths returns the set of the files in the index and of every
+// directory holding one of them.
+func trackedPaths(index map[string]IndexEntry) map[string]bool {
+	tracked := make(map[string]bool, len(index))
+	for filePath := range index {
+		for p := filePath; p != "."; p = path.Dir(p) {
+			if tracked[p] {
+				break
+			}
+			tracked[p] = true
+		}
+	}
+	return tracked
+}
+
+// skip reports whether filePath (relative to the repository root) must be
+// left out of the walk.
+func (f *worktreeFilter) skip(filePath string, isDir bool) (bool, error) {
+	filePath = filepath.ToSlash(filepath.Clean(filePath))
+	if filePath == "." {
+		return false, nil
+	}
+	if name := path.Base(filePath); name == ROOT || (isDir && name == ".git") {
+		return true, nil
+	}
+	if f.tracked[filePath] {
+		return false, nil
 	}
-	return false, nil
+	return f.ignore.IsIgnored(filePath, isDir)
 }
@@ -0,0 +1,122 @@
+package gogit
+
+import "testing"
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		pattern  string
+		negated  bool
+		dirOnly  bool
+		anchored bool
+	}{
+		{line: "", ok: false},
+		{line: "# comment", ok: false},
+		{line: "   ", ok: false},
+		{line: "/", ok: false},
+		{line: "*.o", ok: true, pattern: "*.o"},
+		{line: "*.o\r", ok: true, pattern: "*.o"},
+		{line: "*.o  ", ok: true, pattern: "*.o"},
+		{line: `a\ `, ok: true, pattern: `a\ `},
+		{line: `\#hash`, ok: true, pattern: "#hash"},
+		{line: `\!bang`, ok: true, pattern: "!bang"},
+		{line: "!keep.log", ok: true, pattern: "keep.log", negated: true},
+		{line: "build/", ok: true, pattern: "build", dirOnly: true},
+		{line: "/build", ok: true, pattern: "build", anchored: true},
+		{line: "doc/*.txt", ok: true, pattern: "doc/*.txt", anchored: true},
+		{line: "**/tmp/", ok: true, pattern: "**/tmp", dirOnly: true, anchored: true},
+	}
+
+	for _, tt := range tests {
+		rule, ok := parseIgnoreLine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseIgnoreLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rule.pattern != tt.pattern || rule.negated != tt.negated || rule.dirOnly != tt.dirOnly || rule.anchored != tt.anchored {
+			t.Errorf("parseIgnoreLine(%q) = {pattern %q, negated %v, dirOnly %v, anchored %v}, want {%q, %v, %v, %v}",
+				tt.line, rule.pattern, rule.negated, rule.dirOnly, rule.anchored,
+				tt.pattern, tt.negated, tt.dirOnly, tt.anchored)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		// Unanchored patterns match the name at any depth.
+		{[]string{"*.o"}, "a.o", false, true},
+		{[]string{"*.o"}, "dir/b.o", false, true},
+		{[]string{"*.o"}, "a.c", false, false},
+
+		// A leading or middle slash anchors the pattern.
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "src/build", true, false},
+		{[]string{"foo/*.c"}, "foo/a.c", false, true},
+		{[]string{"foo/*.c"}, "foo/bar/a.c", false, false},
+		{[]string{"**/tmp"}, "a/b/tmp", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+
+		// A trailing slash only matches directories, and hides their content.
+		{[]string{"doc/"}, "doc", true, true},
+		{[]string{"doc/"}, "doc", false, false},
+		{[]string{"doc/"}, "doc/x.txt", false, true},
+		{[]string{"doc/"}, "src/doc/x.txt", false, true},
+
+		// The last matching pattern wins, and "!" re-includes.
+		{[]string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{[]string{"*.log", "!keep.log"}, "other.log", false, true},
+		{[]string{"!keep.log", "*.log"}, "keep.log", false, true},
+
+		// A file cannot be re-included when its directory is excluded.
+		{[]string{"logs/", "!logs/keep.log"}, "logs/keep.log", false, true},
+		{[]string{"logs/*", "!logs/keep.log"}, "logs/keep.log", false, false},
+
+		// Escapes and special characters.
+		{[]string{`\#hash`}, "#hash", false, true},
+		{[]string{`\!bang`}, "!bang", false, true},
+		{[]string{"a.txt  "}, "a.txt", false, true},
+		{[]string{"# comment"}, "# comment", false, false},
+	}
+
+	for _, tt := range tests {
+		m := newPatternMatcher(tt.patterns)
+		got, err := m.IsIgnored(tt.path, tt.isDir)
+		if err != nil {
+			t.Errorf("IsIgnored(%q) with %q: %v", tt.path, tt.patterns, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("IsIgnored(%q, %v) with %q = %v, want %v", tt.path, tt.isDir, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRuleBase(t *testing.T) {
+	// A rule read from sub/.gogitignore only applies below sub.
+	rule, _ := parseIgnoreLine("/out")
+	rule.base = "sub"
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"sub/out", true},
+		{"out", false},
+		{"sub/deep/out", false},
+		{"subway/out", false},
+	}
+	for _, tt := range tests {
+		if got := rule.matches(tt.path, true); got != tt.want {
+			t.Errorf("rule %q of sub matching %q = %v, want %v", rule.text, tt.path, got, tt.want)
+		}
+	}
+}
@@ -49,7 +49,7 @@ func InitRepo(path string) error {
 	}
 
 	// Create .gogitignore file
-	gogitignoreContent := []byte(".gogit\n.git\nmaind")
+	gogitignoreContent := []byte(".gogit\n.git\nmaind\n")
 	if err := os.WriteFile(IgnorePath, gogitignoreContent, 0644); err != nil {
 		return fmt.Errorf("error creating .gogitignore file: %w", err)
 	}
@@ -69,3 +69,39 @@ func InitRepo(path string) error {
 	fmt.Printf("Initializing empty GoGit repository in %s/%s\n", wd, RepoPath)
 	return nil
 }
+
+// InitBareRepo creates a bare repository in dir, for central storage that
+// nobody works in: HEAD, the objects, the refs and the config are at the top
+// of dir and there is no working tree nor index.
+func InitBareRepo(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if isRepositoryDir(absDir) {
+		return fmt.Errorf("gogit repository already exists in %s", dir)
+	}
+
+	for _, sub := range []string{OBJECTS, REF_HEADS} {
+		if err := os.MkdirAll(filepath.Join(absDir, sub), 0755); err != nil {
+			return fmt.Errorf("error creating directory %s: %w", sub, err)
+		}
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{HEAD, "ref: refs/heads/main\n"},
+		{filepath.Join(REF_HEADS, "main"), ""},
+		{"config", "[core]\n\tbare = true\n"},
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(absDir, file.name), []byte(file.content), 0644); err != nil {
+			return fmt.Errorf("error creating %s file: %w", file.name, err)
+		}
+	}
+
+	fmt.Printf("Initializing empty GoGit repository in %s\n", absDir)
+	return nil
+}
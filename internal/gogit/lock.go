@@ -0,0 +1,186 @@
+package gogit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lockSuffix is appended to a file name to build its lock file name.
+	lockSuffix = ".lock"
+	// lockTimeout is how long AcquireLock waits for another process to
+	// release a lock before giving up.
+	lockTimeout = 2 * time.Second
+	// lockRetryInterval is the delay between two attempts to take a lock.
+	lockRetryInterval = 50 * time.Millisecond
+	// staleLockAge is the age after which a lock whose owner cannot be
+	// determined is considered abandoned.
+	staleLockAge = 10 * time.Minute
+)
+
+// ErrLocked is returned when a lock is held by another gogit process.
+var ErrLocked = errors.New("another gogit process seems to be running in this repository")
+
+// Lockfile guards a file against concurrent writers, like Git's "<file>.lock".
+// The lock is taken by creating the lock file with O_EXCL; the new content is
+// then written into it and renamed over the original file, so readers never
+// see a half-written file.
+type Lockfile struct {
+	path     string
+	lockPath string
+	file     *os.File
+	done     bool
+}
+
+// AcquireLock takes the lock for path. While the lock is held by another
+// process it retries for a short while. A lock left behind by a process that
+// no longer exists is reported right away, but never removed: another
+// process could take the lock between the check and the removal.
+func AcquireLock(path string) (*Lockfile, error) {
+	lockPath := path + lockSuffix
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("error creating directory for %s: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			// Record the owner so that a crashed process can be detected.
+			if _, err := fmt.Fprintf(file, "pid %d\n", os.Getpid()); err != nil {
+				file.Close()
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("error writing lock file %s: %w", lockPath, err)
+			}
+			return &Lockfile{path: path, lockPath: lockPath, file: file}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("fatal: unable to create '%s': %w", lockPath, err)
+		}
+
+		if owner := staleLockOwner(lockPath); owner != "" {
+			return nil, fmt.Errorf("fatal: unable to create '%s': file exists.\n\n"+
+				"The lock was left behind by %s.\n"+
+				"Make sure no gogit process is running, then remove the file manually\n"+
+				"to continue", lockPath, owner)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("fatal: unable to create '%s': file exists.\n\n"+
+				"%w, e.g. an editor opened by 'gogit commit'.\n"+
+				"Please make sure all processes are terminated then try again.\n"+
+				"If it still fails, a gogit process may have crashed in this\n"+
+				"repository earlier: remove the file manually to continue", lockPath, ErrLocked)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Commit replaces the locked file with content and releases the lock.
+func (l *Lockfile) Commit(content []byte) error {
+	if l.done {
+		return fmt.Errorf("lock on %s already released", l.path)
+	}
+	l.done = true
+
+	if err := l.file.Truncate(0); err != nil {
+		l.abort()
+		return fmt.Errorf("error truncating %s: %w", l.lockPath, err)
+	}
+	if _, err := l.file.WriteAt(content, 0); err != nil {
+		l.abort()
+		return fmt.Errorf("error writing %s: %w", l.lockPath, err)
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.lockPath)
+		return fmt.Errorf("error closing %s: %w", l.lockPath, err)
+	}
+
+	if err := os.Rename(l.lockPath, l.path); err != nil {
+		os.Remove(l.lockPath)
+		return fmt.Errorf("error renaming %s to %s: %w", l.lockPath, l.path, err)
+	}
+	return nil
+}
+
+// Rollback releases the lock without touching the locked file. It is a no-op
+// once the lock has been committed, so it can always be deferred.
+func (l *Lockfile) Rollback() {
+	if l.done {
+		return
+	}
+	l.done = true
+	l.abort()
+}
+
+func (l *Lockfile) abort() {
+	l.file.Close()
+	os.Remove(l.lockPath)
+}
+
+// staleLockOwner describes the owner of a lock file left behind by a process
+// that no longer runs, or returns an empty string when the lock looks live.
+// Locks without a readable owner are only considered stale once they are old
+// enough.
+func staleLockOwner(lockPath string) string {
+	file, err := os.Open(lockPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+
+	owner := ""
+	if time.Since(info.ModTime()) > staleLockAge {
+		owner = fmt.Sprintf("a process that has not touched it for %s", staleLockAge)
+	}
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		if pidText, found := strings.CutPrefix(scanner.Text(), "pid "); found {
+			if pid, err := strconv.Atoi(pidText); err == nil {
+				owner = ""
+				if !processAlive(pid) {
+					owner = fmt.Sprintf("process %d, which is no longer running", pid)
+				}
+			}
+		}
+	}
+	if owner == "" {
+		return ""
+	}
+
+	// The owner may have released the lock, and another process taken it,
+	// while we were looking: only report the very file we inspected.
+	current, err := os.Lstat(lockPath)
+	if err != nil || !os.SameFile(info, current) {
+		return ""
+	}
+	return owner
+}
+
+// LockIndex takes the index lock (.gogit/index.lock).
+func LockIndex() (*Lockfile, error) {
+	return AcquireLock(IndexPath)
+}
+
+// writeLockedFile atomically replaces the content of a small repository file,
+// such as HEAD or a branch ref, while holding its lock.
+func writeLockedFile(path string, content []byte) error {
+	lock, err := AcquireLock(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	return lock.Commit(content)
+}
@@ -1,15 +1,98 @@
 package gogit
 
-func LogRepo() error {
+import "fmt"
+
+// LogOptions controls what LogRepo prints for each commit.
+type LogOptions struct {
+	// NameStatus lists the paths changed by each commit with their status
+	// letter (`--name-status`).
+	NameStatus bool
+	// Renames sets the detection of renamed and copied files.
+	Renames RenameOptions
+}
+
+// LogRepo prints the history of the current branch. When pathspecs are
+// given, only the commits that changed a matching path are shown.
+func LogRepo(pathspecs []string, opts LogOptions) error {
 	currentHash, err := GetBranchHash()
 	if err != nil {
 		return err
 	}
+	if currentHash == "" {
+		return fmt.Errorf("fatal: your current branch does not have any commits yet")
+	}
 
-	err = ReadObject(currentHash)
+	spec, err := ParsePathspec(pathspecs)
 	if err != nil {
 		return err
 	}
 
+	for hash := currentHash; hash != ""; {
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+
+		// The trees are only compared when something depends on it.
+		var changes []fileChange
+		if opts.NameStatus || !spec.IsEmpty() {
+			renames := opts.Renames
+			if !opts.NameStatus {
+				renames = RenameOptions{}
+			}
+			if changes, err = commitChanges(commit, spec, renames); err != nil {
+				return err
+			}
+		}
+		if len(changes) > 0 || spec.IsEmpty() {
+			PrintCommit(commit)
+			if opts.NameStatus && len(changes) > 0 {
+				for _, change := range changes {
+					fmt.Println(change.nameStatus())
+				}
+				fmt.Println()
+			}
+		}
+
+		hash = commit.Parent
+	}
+
 	return nil
 }
+
+// commitChanges returns the changes made by commit to the paths selected by
+// spec, compared to its parent.
+func commitChanges(commit *Commit, spec *Pathspec, renames RenameOptions) ([]fileChange, error) {
+	tree, err := ReadTree(commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := make(map[string]TreeEntry)
+	if commit.Parent != "" {
+		parent, err := ReadCommit(commit.Parent)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err = ReadTree(parent.Tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffTrees(filterTree(parentTree, spec), filterTree(tree, spec), renames, nil)
+}
+
+// filterTree returns the entries of tree selected by spec.
+func filterTree(tree map[string]TreeEntry, spec *Pathspec) map[string]TreeEntry {
+	if spec.IsEmpty() {
+		return tree
+	}
+	filtered := make(map[string]TreeEntry)
+	for path, entry := range tree {
+		if spec.Match(path) {
+			filtered[path] = entry
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,66 @@
+package gogit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LsFilesOptions controls the output of LsFiles.
+type LsFilesOptions struct {
+	// Verbose prefixes each path with a tag describing its index flags.
+	Verbose bool
+}
+
+// LsFiles prints the paths in the index selected by the pathspecs, or the
+// ones under the current directory without pathspecs, relative to the
+// current directory.
+//
+// With Verbose each path is preceded by a tag: "H" for a regular entry and
+// "S" for a skip-worktree entry, in lowercase when the entry is marked
+// assume-unchanged.
+func LsFiles(pathspecs []string, opts LsFilesOptions) error {
+	spec, err := ParsePathspec(pathspecs)
+	if err != nil {
+		return err
+	}
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	under := ""
+	if spec.IsEmpty() && prefix != "" {
+		under = prefix + "/"
+	}
+
+	var paths []string
+	for path := range indexEntries {
+		if spec.Match(path) && strings.HasPrefix(path, under) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if !opts.Verbose {
+			fmt.Println(displayPath(path))
+			continue
+		}
+		fmt.Printf("%c %s\n", lsFilesTag(indexEntries[path]), displayPath(path))
+	}
+
+	return spec.CheckUnmatched()
+}
+
+func lsFilesTag(entry IndexEntry) rune {
+	tag := 'H'
+	if entry.SkipWorktree {
+		tag = 'S'
+	}
+	if entry.AssumeUnchanged {
+		tag += 'a' - 'A'
+	}
+	return tag
+}
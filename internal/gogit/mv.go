@@ -0,0 +1,163 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MoveOptions controls the behaviour of Move (equivalent to `git mv`).
+type MoveOptions struct {
+	// Force overwrites an existing destination file.
+	Force bool
+	// SkipErrors skips the sources that cannot be moved instead of failing.
+	SkipErrors bool
+}
+
+// move is a single validated rename of a file or directory.
+type move struct {
+	src, dst string
+	// paths are the index entries affected by the move, relative to src.
+	paths []string
+}
+
+// Move renames tracked files or directories in both the working tree and the
+// index. All moves are validated before anything is touched, and the renames
+// already done are rolled back if one of them fails.
+func Move(sources []string, destination string, opts MoveOptions) error {
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	dest, inside := rootPath(destination)
+	if !inside {
+		return fmt.Errorf("fatal: '%s' is outside repository", destination)
+	}
+	destination = dest
+	destInfo, err := os.Stat(destination)
+	destIsDir := err == nil && destInfo.IsDir()
+	if len(sources) > 1 && !destIsDir {
+		return fmt.Errorf("fatal: destination '%s' is not a directory", destination)
+	}
+
+	// 1. Validate every move up front.
+	var moves []move
+	targets := make(map[string]string)
+	for _, source := range sources {
+		src, inside := rootPath(source)
+		if !inside {
+			return fmt.Errorf("fatal: '%s' is outside repository", source)
+		}
+		dst := destination
+		if destIsDir {
+			dst = path.Join(destination, path.Base(src))
+		}
+
+		m, err := planMove(src, dst, indexEntries, opts.Force)
+		if err == nil {
+			if other, exists := targets[dst]; exists {
+				err = fmt.Errorf("multiple sources for the same target (%s and %s)", other, src)
+			}
+		}
+		if err != nil {
+			if opts.SkipErrors {
+				continue
+			}
+			return fmt.Errorf("fatal: %w, source=%s, destination=%s", err, src, dst)
+		}
+
+		targets[dst] = src
+		moves = append(moves, m)
+	}
+
+	// 2. Rename in the working tree. The renames done so far are undone if
+	// anything fails afterwards, up to writing the index.
+	done := 0
+	defer func() {
+		for j := done - 1; j >= 0; j-- {
+			_ = os.Rename(moves[j].dst, moves[j].src)
+		}
+	}()
+	for _, m := range moves {
+		if err := os.Rename(m.src, m.dst); err != nil {
+			return fmt.Errorf("fatal: renaming '%s' failed: %w", m.src, err)
+		}
+		done++
+	}
+
+	// 3. Rewrite the index entries under their new names.
+	for _, m := range moves {
+		for _, rel := range m.paths {
+			oldPath, newPath := m.src, m.dst
+			if rel != "" {
+				oldPath, newPath = m.src+"/"+rel, m.dst+"/"+rel
+			}
+			indexEntries[newPath] = indexEntries[oldPath]
+			delete(indexEntries, oldPath)
+		}
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	done = 0
+
+	return nil
+}
+
+// planMove checks that src can be renamed to dst and returns the index
+// entries that follow it.
+func planMove(src, dst string, indexEntries map[string]IndexEntry, force bool) (move, error) {
+	m := move{src: src, dst: dst}
+
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return m, errors.New("bad source")
+	}
+
+	if srcInfo.IsDir() {
+		if dst == src || strings.HasPrefix(dst, src+"/") {
+			return m, errors.New("can not move directory into itself")
+		}
+		for indexPath := range indexEntries {
+			if strings.HasPrefix(indexPath, src+"/") {
+				m.paths = append(m.paths, strings.TrimPrefix(indexPath, src+"/"))
+			}
+		}
+		if len(m.paths) == 0 {
+			return m, errors.New("source directory is empty")
+		}
+	} else {
+		if _, tracked := indexEntries[src]; !tracked {
+			return m, errors.New("not under version control")
+		}
+		m.paths = []string{""}
+	}
+
+	if dstInfo, err := os.Lstat(dst); err == nil {
+		if srcInfo.IsDir() || dstInfo.IsDir() {
+			return m, errors.New("destination already exists")
+		}
+		if !force {
+			return m, errors.New("destination exists")
+		}
+	}
+
+	if parent := filepath.Dir(dst); parent != "." {
+		if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+			return m, errors.New("destination directory does not exist")
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,79 @@
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of operations that can be left in progress in a repository.
+const (
+	OperationMerge      = "merge"
+	OperationRebase     = "rebase"
+	OperationAm         = "am"
+	OperationCherryPick = "cherry-pick"
+	OperationRevert     = "revert"
+)
+
+// Operation describes an operation that stopped half-way, for example a
+// merge waiting for conflicts to be resolved. The state files are the ones
+// Git uses: MERGE_HEAD, rebase-merge/, rebase-apply/, CHERRY_PICK_HEAD and
+// REVERT_HEAD.
+type Operation struct {
+	Kind string
+	// Interactive is set for an interactive rebase.
+	Interactive bool
+	// Branch is the branch being rebased.
+	Branch string
+	// Onto is the commit a rebase replays onto.
+	Onto string
+	// Commit is the commit being cherry-picked or reverted.
+	Commit string
+}
+
+// InProgressOperation returns the operation in progress, or nil when there
+// is none.
+func InProgressOperation() *Operation {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		stateDir := filepath.Join(RepoPath, dir)
+		if info, err := os.Stat(stateDir); err != nil || !info.IsDir() {
+			continue
+		}
+		if dir == "rebase-apply" && fileExists(filepath.Join(stateDir, "applying")) {
+			return &Operation{Kind: OperationAm}
+		}
+		return &Operation{
+			Kind:        OperationRebase,
+			Interactive: fileExists(filepath.Join(stateDir, "interactive")),
+			Branch:      strings.TrimPrefix(readStateFile(filepath.Join(stateDir, "head-name")), "refs/heads/"),
+			Onto:        readStateFile(filepath.Join(stateDir, "onto")),
+		}
+	}
+
+	if fileExists(filepath.Join(RepoPath, "MERGE_HEAD")) {
+		return &Operation{Kind: OperationMerge}
+	}
+	if commit := readStateFile(filepath.Join(RepoPath, "CHERRY_PICK_HEAD")); commit != "" {
+		return &Operation{Kind: OperationCherryPick, Commit: commit}
+	}
+	if commit := readStateFile(filepath.Join(RepoPath, "REVERT_HEAD")); commit != "" {
+		return &Operation{Kind: OperationRevert, Commit: commit}
+	}
+	return nil
+}
+
+// readStateFile returns the first line of a state file, or an empty string
+// when it cannot be read.
+func readStateFile(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(content), "\n")
+	return strings.TrimSpace(line)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,254 @@
+package gogit
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pathspec is a parsed list of pathspec arguments, shared by every command
+// that accepts paths. Each argument is one of:
+//
+//	foo/bar.go        the file, or everything under the directory foo/bar.go
+//	*.go, src/**      wildcards; "*" also matches "/" unless the glob magic is used
+//	:(exclude)gen/*   excludes matching paths (short forms ":!gen/*" and ":^gen/*")
+//	:(top)README.md   relative to the repository root (short form ":/README.md")
+//	                  instead of the directory the command was run from
+//	:(literal)a*b     no wildcard expansion
+//	:(glob)src/*.go   wildcards follow pathname rules, "**" crosses directories
+//	:(icase)readme    case-insensitive match
+//
+// Magic words can be combined: ":(top,exclude)vendor".
+type Pathspec struct {
+	items    []*pathspecItem
+	includes int
+}
+
+type pathspecItem struct {
+	original string // as typed, for error messages
+	pattern  string // relative to the repository root, "" means everything
+	exclude  bool
+	literal  bool
+	glob     bool
+	icase    bool
+	used     bool
+}
+
+// matchAll is the implicit include used when only exclusions are given.
+var matchAll = &pathspecItem{}
+
+// ParsePathspec parses pathspec arguments. Paths are relative to the
+// directory the command was run from, unless the top magic is used. An empty
+// list matches every path.
+func ParsePathspec(args []string) (*Pathspec, error) {
+	ps := &Pathspec{}
+	for _, arg := range args {
+		item, err := parsePathspecItem(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !item.exclude {
+			ps.includes++
+		}
+		ps.items = append(ps.items, item)
+	}
+	return ps, nil
+}
+
+func parsePathspecItem(arg string) (*pathspecItem, error) {
+	item := &pathspecItem{original: arg}
+	pattern := arg
+	top := false
+
+	if strings.HasPrefix(pattern, ":(") {
+		// Long form: ":(magic,magic)pattern".
+		end := strings.IndexByte(pattern, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("fatal: missing ')' at the end of pathspec magic in '%s'", arg)
+		}
+		for _, word := range strings.Split(pattern[2:end], ",") {
+			switch strings.TrimSpace(word) {
+			case "top":
+				top = true
+			case "exclude":
+				item.exclude = true
+			case "literal":
+				item.literal = true
+			case "glob":
+				item.glob = true
+			case "icase":
+				item.icase = true
+			case "":
+			default:
+				return nil, fmt.Errorf("fatal: invalid pathspec magic '%s' in '%s'", word, arg)
+			}
+		}
+		pattern = pattern[end+1:]
+	} else if strings.HasPrefix(pattern, ":") {
+		// Short form: ":" followed by magic signatures and an optional ":".
+		i := 1
+		for ; i < len(pattern) && strings.IndexByte("!^/", pattern[i]) >= 0; i++ {
+			if pattern[i] == '/' {
+				top = true
+			} else {
+				item.exclude = true
+			}
+		}
+		if i < len(pattern) && pattern[i] == ':' {
+			i++
+		}
+		pattern = pattern[i:]
+	}
+
+	if item.literal && item.glob {
+		return nil, fmt.Errorf("fatal: 'literal' and 'glob' are incompatible in '%s'", arg)
+	}
+
+	if top {
+		pattern = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(pattern)), "/")
+	} else {
+		var inside bool
+		if pattern, inside = rootPath(pattern); !inside {
+			return nil, fmt.Errorf("fatal: %s: '%s' is outside repository", arg, arg)
+		}
+	}
+	if pattern == "." {
+		pattern = ""
+	}
+	if item.icase {
+		pattern = strings.ToLower(pattern)
+	}
+
+	item.pattern = pattern
+	return item, nil
+}
+
+// IsEmpty reports whether no pathspec was given, i.e. everything matches.
+func (ps *Pathspec) IsEmpty() bool {
+	return len(ps.items) == 0
+}
+
+// Match reports whether path (relative to the repository root) is selected
+// by the pathspec. It also records which arguments matched something, for
+// Unmatched.
+func (ps *Pathspec) Match(path string) bool {
+	return ps.matchItem(path) != nil
+}
+
+// matchItem returns the first include item that selects path, or nil when
+// path is excluded or not selected. Every matching item is marked as used.
+func (ps *Pathspec) matchItem(path string) *pathspecItem {
+	for _, item := range ps.items {
+		if item.exclude && item.matches(path) {
+			return nil
+		}
+	}
+	if ps.includes == 0 {
+		return matchAll
+	}
+
+	var first *pathspecItem
+	for _, item := range ps.items {
+		if !item.exclude && item.matches(path) {
+			item.used = true
+			if first == nil {
+				first = item
+			}
+		}
+	}
+	return first
+}
+
+// Unmatched returns the include arguments that did not match any path
+// passed to Match so far.
+func (ps *Pathspec) Unmatched() []string {
+	var unmatched []string
+	for _, item := range ps.items {
+		if !item.exclude && !item.used {
+			unmatched = append(unmatched, item.original)
+		}
+	}
+	return unmatched
+}
+
+// CheckUnmatched returns the usual "did not match any files" error for the
+// first argument that matched nothing.
+func (ps *Pathspec) CheckUnmatched() error {
+	if unmatched := ps.Unmatched(); len(unmatched) > 0 {
+		return fmt.Errorf("fatal: pathspec '%s' did not match any files", unmatched[0])
+	}
+	return nil
+}
+
+// WalkRoots returns the directories (or files) that must be walked to find
+// every path the pathspec can match, without overlaps.
+func (ps *Pathspec) WalkRoots() []string {
+	var roots []string
+	for _, item := range ps.items {
+		if !item.exclude {
+			roots = append(roots, item.walkRoot())
+		}
+	}
+	if len(roots) == 0 {
+		return []string{"."}
+	}
+
+	sort.Strings(roots)
+	var result []string
+	for _, root := range roots {
+		if len(result) > 0 && pathInScope(root, result[len(result)-1]) {
+			continue
+		}
+		result = append(result, root)
+	}
+	return result
+}
+
+// isPrefixMatch reports whether the item selects a file or directory by name
+// rather than with wildcards.
+func (item *pathspecItem) isPrefixMatch() bool {
+	return item.literal || !hasWildcard(item.pattern)
+}
+
+func (item *pathspecItem) matches(filePath string) bool {
+	if item.pattern == "" {
+		return true
+	}
+	if item.icase {
+		filePath = strings.ToLower(filePath)
+	}
+
+	if item.isPrefixMatch() {
+		return pathInScope(filePath, item.pattern)
+	}
+
+	if wildmatch(item.pattern, filePath, item.glob) {
+		return true
+	}
+	// A pattern matching a leading directory selects everything inside it.
+	for i := 0; i < len(filePath); i++ {
+		if filePath[i] == '/' && wildmatch(item.pattern, filePath[:i], item.glob) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkRoot is the longest leading directory of the item without wildcards.
+func (item *pathspecItem) walkRoot() string {
+	if item.pattern == "" || item.icase {
+		return "."
+	}
+	if item.isPrefixMatch() {
+		return item.pattern
+	}
+
+	literal := item.pattern[:strings.IndexAny(item.pattern, "*?[\\")]
+	slash := strings.LastIndexByte(literal, '/')
+	if slash < 0 {
+		return "."
+	}
+	return literal[:slash]
+}
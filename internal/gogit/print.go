@@ -2,7 +2,9 @@ package gogit
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 )
 
 // PrintCommit prints a commit object with a stylized format.
@@ -17,49 +19,374 @@ func PrintCommit(commit *Commit) {
 	fmt.Printf("\n\t%s\n\n", commit.Message)
 }
 
+// PrintStatus prints the status in the long, human readable format.
 func PrintStatus(statusInfo *StatusInfo) {
 	// Print the current branch
-	fmt.Printf("On branch %s\n", statusInfo.Branch)
+	if statusInfo.Branch == "" {
+		fmt.Printf("HEAD detached at %s\n", ShortHash(statusInfo.Head))
+	} else {
+		fmt.Printf("On branch %s\n", statusInfo.Branch)
+	}
+	if tracking := trackingSummary(statusInfo); tracking != "" {
+		fmt.Println(tracking)
+	}
+	if statusInfo.Operation != nil {
+		fmt.Printf("\n%s\n", operationBanner(statusInfo.Operation))
+	}
+	if statusInfo.Head == "" {
+		fmt.Println("\nNo commits yet")
+	}
 
-	// Variable to know if the repository is clean
-	isClean := true
+	var staged, unstaged, untracked, ignored []string
+	for _, entry := range statusInfo.Entries {
+		switch {
+		case entry.IndexStatus == StatusUntracked:
+			untracked = append(untracked, quotePath(displayPath(entry.Path)))
+			continue
+		case entry.IndexStatus == StatusIgnored:
+			ignored = append(ignored, quotePath(displayPath(entry.Path)))
+			continue
+		case entry.IndexStatus != StatusUnmodified:
+			staged = append(staged, statusLabel(entry.IndexStatus)+statusPaths(entry))
+		}
+		if entry.WorktreeStatus != StatusUnmodified {
+			unstaged = append(unstaged, statusLabel(entry.WorktreeStatus)+quotePath(displayPath(entry.Path)))
+		}
+	}
 
 	// Show files ready for commit (Staged)
-	if len(statusInfo.Staged) > 0 {
-		isClean = false
+	if len(staged) > 0 {
 		fmt.Println("\nChanges to be committed:")
 		fmt.Println("  (use \"gogit reset <file>...\" to unstage)")
-		for _, file := range statusInfo.Staged {
+		for _, file := range staged {
 			fmt.Printf("%s\t%s%s\n", ColorGreen, file, ColorReset)
 		}
 	}
 
 	// Show files with changes not staged for commit (Unstaged)
-	if len(statusInfo.Unstaged) > 0 {
-		isClean = false
+	if len(unstaged) > 0 {
 		fmt.Println("\nChanges not staged for commit:")
-		fmt.Println("  (use \"gogit add <file>...\" to update what will be committed)")
-		for _, file := range statusInfo.Unstaged {
+		fmt.Println("  (use \"gogit add/rm <file>...\" to update what will be committed)")
+		for _, file := range unstaged {
 			fmt.Printf("%s\t%s%s\n", ColorRed, file, ColorReset)
 		}
 	}
 
 	// Show untracked files
-	if len(statusInfo.Untracked) > 0 {
-		isClean = false
+	if len(untracked) > 0 {
 		fmt.Println("\nUntracked files:")
 		fmt.Println("  (use \"gogit add <file>...\" to include in what will be committed)")
-		for _, file := range statusInfo.Untracked {
+		for _, file := range untracked {
+			fmt.Printf("%s        %s%s\n", ColorRed, file, ColorReset)
+		}
+	}
+
+	// Show ignored files
+	if len(ignored) > 0 {
+		fmt.Println("\nIgnored files:")
+		for _, file := range ignored {
 			fmt.Printf("%s        %s%s\n", ColorRed, file, ColorReset)
 		}
 	}
 
 	// If there are no changes in any section, the working tree is clean
-	if isClean {
+	changed := len(staged) > 0 || len(unstaged) > 0 || len(untracked) > 0
+	switch {
+	case !changed && statusInfo.UntrackedHidden:
+		fmt.Println("\nnothing to commit (use -u to show untracked files)")
+	case !changed:
 		fmt.Println("\nnothing to commit, working tree clean")
+	case statusInfo.UntrackedHidden:
+		fmt.Println("\nUntracked files not listed (use -u option to show untracked files)")
+	}
+}
+
+// PrintShortStatus prints one "XY path" line per entry. --short is colored
+// when useColors allows it; --porcelain never is.
+func PrintShortStatus(statusInfo *StatusInfo, opts StatusOptions) error {
+	terminator := "\n"
+	if opts.NullTerminated {
+		terminator = "\x00"
+	}
+	colors := false
+	if opts.Format == StatusFormatShort && !opts.NullTerminated {
+		var err error
+		if colors, err = useColors(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if opts.Branch {
+		fmt.Printf("## %s%s", shortBranchHeader(statusInfo), terminator)
+	}
+
+	for _, entry := range statusInfo.Entries {
+		x, y := string(entry.IndexStatus), string(entry.WorktreeStatus)
+		if colors {
+			switch {
+			case entry.IndexStatus == StatusUntracked || entry.IndexStatus == StatusIgnored:
+				x = colorize(x, ColorRed)
+			case entry.IndexStatus != StatusUnmodified:
+				x = colorize(x, ColorGreen)
+			}
+			if entry.WorktreeStatus != StatusUnmodified {
+				y = colorize(y, ColorRed)
+			}
+		}
+
+		switch {
+		case entry.OrigPath == "":
+			fmt.Printf("%s%s %s%s", x, y, formatStatusPath(entry.Path, opts), terminator)
+		case opts.NullTerminated:
+			// With -z the source comes after the destination, in its own field.
+			fmt.Printf("%s%s %s\x00%s\x00", x, y, formatStatusPath(entry.Path, opts), formatStatusPath(entry.OrigPath, opts))
+		default:
+			fmt.Printf("%s%s %s -> %s%s", x, y, formatStatusPath(entry.OrigPath, opts), formatStatusPath(entry.Path, opts), terminator)
+		}
+	}
+	return nil
+}
+
+// PrintPorcelainV2Status prints the status in the porcelain v2 format:
+//
+//	1 XY sub mH mI mW hH hI path
+//	2 XY sub mH mI mW hH hI Xscore path<tab>origPath
+//	? path
+//	! path
+//
+// where unmodified states are shown as ".".
+func PrintPorcelainV2Status(statusInfo *StatusInfo, opts StatusOptions) {
+	terminator := "\n"
+	if opts.NullTerminated {
+		terminator = "\x00"
+	}
+
+	if opts.Branch {
+		oid := statusInfo.Head
+		if oid == "" {
+			oid = "(initial)"
+		}
+		branch := statusInfo.Branch
+		if branch == "" {
+			branch = "(detached)"
+		}
+		fmt.Printf("# branch.oid %s%s", oid, terminator)
+		fmt.Printf("# branch.head %s%s", branch, terminator)
+		if upstream := statusInfo.Upstream; upstream != nil {
+			fmt.Printf("# branch.upstream %s%s", upstream.Name, terminator)
+			if upstream.Hash != "" {
+				fmt.Printf("# branch.ab +%d -%d%s", statusInfo.Ahead, statusInfo.Behind, terminator)
+			}
+		}
+	}
+
+	for _, entry := range statusInfo.Entries {
+		if entry.IndexStatus == StatusUntracked || entry.IndexStatus == StatusIgnored {
+			fmt.Printf("%c %s%s", entry.IndexStatus, formatStatusPath(entry.Path, opts), terminator)
+			continue
+		}
+
+		xy := strings.ReplaceAll(string([]byte{entry.IndexStatus, entry.WorktreeStatus}), " ", ".")
+		fields := fmt.Sprintf("%s N... %s %s %s %s %s", xy,
+			porcelainMode(entry.HeadMode), porcelainMode(entry.IndexMode), porcelainMode(entry.WorktreeMode),
+			porcelainHash(entry.HeadHash), porcelainHash(entry.IndexHash))
+
+		if entry.OrigPath == "" {
+			fmt.Printf("1 %s %s%s", fields, formatStatusPath(entry.Path, opts), terminator)
+			continue
+		}
+		separator := "\t"
+		if opts.NullTerminated {
+			separator = "\x00"
+		}
+		fmt.Printf("2 %s %c%d %s%s%s%s", fields, entry.IndexStatus, entry.Score,
+			formatStatusPath(entry.Path, opts), separator, formatStatusPath(entry.OrigPath, opts), terminator)
+	}
+}
+
+// trackingSummary describes how the branch compares with its upstream, in
+// the long format. It is empty when there is no upstream.
+func trackingSummary(statusInfo *StatusInfo) string {
+	upstream := statusInfo.Upstream
+	if upstream == nil {
+		return ""
+	}
+
+	ahead, behind := statusInfo.Ahead, statusInfo.Behind
+	switch {
+	case upstream.Hash == "":
+		return fmt.Sprintf("Your branch is based on '%s', but the upstream is gone.", upstream.Name)
+	case ahead > 0 && behind > 0:
+		return fmt.Sprintf("Your branch and '%s' have diverged,\nand have %d and %d different commits each, respectively.",
+			upstream.Name, ahead, behind)
+	case ahead > 0:
+		return fmt.Sprintf("Your branch is ahead of '%s' by %s.", upstream.Name, pluralCommits(ahead))
+	case behind > 0:
+		return fmt.Sprintf("Your branch is behind '%s' by %s, and can be fast-forwarded.", upstream.Name, pluralCommits(behind))
+	default:
+		return fmt.Sprintf("Your branch is up to date with '%s'.", upstream.Name)
 	}
 }
 
+// shortBranchHeader is the "## ..." line of the short format, such as
+// "main...origin/main [ahead 1, behind 2]".
+func shortBranchHeader(statusInfo *StatusInfo) string {
+	switch {
+	case statusInfo.Branch == "":
+		return "HEAD (no branch)"
+	case statusInfo.Head == "":
+		return "No commits yet on " + statusInfo.Branch
+	}
+
+	header := statusInfo.Branch
+	upstream := statusInfo.Upstream
+	if upstream == nil {
+		return header
+	}
+	header += "..." + upstream.Name
+
+	var counts []string
+	if statusInfo.Ahead > 0 {
+		counts = append(counts, fmt.Sprintf("ahead %d", statusInfo.Ahead))
+	}
+	if statusInfo.Behind > 0 {
+		counts = append(counts, fmt.Sprintf("behind %d", statusInfo.Behind))
+	}
+	switch {
+	case upstream.Hash == "":
+		header += " [gone]"
+	case len(counts) > 0:
+		header += " [" + strings.Join(counts, ", ") + "]"
+	}
+	return header
+}
+
+// operationBanner describes an operation in progress in the long format.
+func operationBanner(op *Operation) string {
+	switch op.Kind {
+	case OperationMerge:
+		return "All conflicts fixed but you are still merging."
+	case OperationRebase:
+		banner := "rebase in progress; onto " + ShortHash(op.Onto)
+		if op.Interactive {
+			banner = "interactive " + banner
+		}
+		if op.Branch != "" {
+			banner += fmt.Sprintf("\nYou are currently rebasing branch '%s' on '%s'.", op.Branch, ShortHash(op.Onto))
+		}
+		return banner
+	case OperationAm:
+		return "You are in the middle of an am session."
+	case OperationCherryPick:
+		return fmt.Sprintf("You are currently cherry-picking commit %s.", ShortHash(op.Commit))
+	case OperationRevert:
+		return fmt.Sprintf("You are currently reverting commit %s.", ShortHash(op.Commit))
+	}
+	return ""
+}
+
+func pluralCommits(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
+
+// statusLabel is the description of a status letter in the long format.
+func statusLabel(status byte) string {
+	switch status {
+	case StatusAdded:
+		return "new file:   "
+	case StatusDeleted:
+		return "deleted:    "
+	case StatusTypeChanged:
+		return "typechange: "
+	case StatusRenamed:
+		return "renamed:    "
+	case StatusCopied:
+		return "copied:     "
+	default:
+		return "modified:   "
+	}
+}
+
+// statusPaths is "orig -> path" for renames and copies, the path otherwise.
+func statusPaths(entry StatusEntry) string {
+	if entry.OrigPath != "" {
+		return quotePath(displayPath(entry.OrigPath)) + " -> " + quotePath(displayPath(entry.Path))
+	}
+	return quotePath(displayPath(entry.Path))
+}
+
+// formatStatusPath quotes path unless entries are NUL terminated. The short
+// format shows paths relative to the current directory; the porcelain
+// formats keep them relative to the repository root, for scripts.
+func formatStatusPath(path string, opts StatusOptions) string {
+	if opts.Format == StatusFormatShort {
+		path = displayPath(path)
+	}
+	if opts.NullTerminated {
+		return path
+	}
+	return quotePath(path)
+}
+
+// quotePath quotes a path the way Git does when it contains double quotes,
+// backslashes, control characters or non-ASCII bytes; other paths are
+// returned as they are.
+func quotePath(path string) string {
+	needsQuotes := false
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return path
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '"', '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(c)
+		case '\t':
+			quoted.WriteString(`\t`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&quoted, "\\%03o", c)
+			} else {
+				quoted.WriteByte(c)
+			}
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+func porcelainMode(mode string) string {
+	if mode == "" {
+		return "000000"
+	}
+	return mode
+}
+
+func porcelainHash(hash string) string {
+	if hash == "" {
+		return strings.Repeat("0", 40)
+	}
+	return hash
+}
+
+func colorize(text, color string) string {
+	return color + text + ColorReset
+}
+
 func PrintBranches(branchMap []string, currentBranch string) {
 	sort.Strings(branchMap)
 	for _, branchName := range branchMap {
@@ -0,0 +1,382 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RenameOptions controls the detection of renamed and copied files.
+type RenameOptions struct {
+	// Renames pairs deleted files with added files of similar content.
+	Renames bool
+	// Copies also pairs added files with the modified files they were
+	// copied from. It implies Renames.
+	Copies bool
+	// Threshold is the minimum similarity of a pair, in percent.
+	Threshold int
+}
+
+const (
+	// DefaultRenameThreshold is the similarity used when none is given.
+	DefaultRenameThreshold = 50
+	// renameLimit bounds the number of files on each side compared by
+	// content; exact renames are always detected.
+	renameLimit = 1000
+	// similarityChunk is the longest piece of content hashed at once.
+	similarityChunk = 64
+)
+
+// ParseRenameThreshold parses the value of -M or -C like Git: "90%" is a
+// percentage, while digits alone are a fraction, so "9" and "90" both mean
+// 90% and "05" means 5%.
+func ParseRenameThreshold(value string) (int, error) {
+	if value == "" {
+		return DefaultRenameThreshold, nil
+	}
+
+	var threshold int
+	var err error
+	if percent, found := strings.CutSuffix(value, "%"); found {
+		threshold, err = strconv.Atoi(percent)
+	} else {
+		var fraction float64
+		fraction, err = strconv.ParseFloat("0."+value, 64)
+		threshold = int(fraction*100 + 0.5)
+	}
+	if err != nil || threshold < 0 || threshold > 100 || strings.ContainsAny(value, "+-") {
+		return 0, fmt.Errorf("fatal: invalid similarity threshold '%s'", value)
+	}
+	return threshold, nil
+}
+
+// ConfigRenameOptions returns the rename detection set by the first of the
+// given variables that is configured ("true", "false" or "copies"), such as
+// status.renames then diff.renames. Renames are detected by default.
+func ConfigRenameOptions(names ...string) (RenameOptions, error) {
+	opts := RenameOptions{Renames: true, Threshold: DefaultRenameThreshold}
+	for _, name := range names {
+		value, isSet, err := ConfigValue(name)
+		if err != nil {
+			return opts, err
+		}
+		if !isSet {
+			continue
+		}
+
+		switch strings.ToLower(value) {
+		case "copies", "copy":
+			opts.Copies = true
+		default:
+			renames, err := strconv.ParseBool(value)
+			if err != nil {
+				return opts, fmt.Errorf("fatal: bad boolean config value '%s' for '%s'", value, name)
+			}
+			opts.Renames = renames
+		}
+		break
+	}
+	return opts, nil
+}
+
+// enabled reports whether anything is to be detected.
+func (opts RenameOptions) enabled() bool {
+	return opts.Renames || opts.Copies
+}
+
+// renamePair is an added file found to come from a file of the old side.
+type renamePair struct {
+	from, to string
+	score    int  // similarity in percent
+	copy     bool // the source is still there
+}
+
+// detectRenames pairs the added files with the deleted files they were
+// renamed from and, with Copies, with the deleted or modified files (sources)
+// they were copied from. Files with the same blob are paired first; the
+// others by similarity of content, most similar first. loadOld and loadNew
+// return the content of a file of each side.
+func detectRenames(deleted, added, sources map[string]TreeEntry, opts RenameOptions, loadOld, loadNew func(filePath string) ([]byte, error)) ([]renamePair, error) {
+	if !opts.enabled() || len(added) == 0 || (len(deleted) == 0 && !opts.Copies) {
+		return nil, nil
+	}
+
+	// The candidates, in a stable order.
+	oldPaths := sortedKeys(deleted)
+	if opts.Copies {
+		oldPaths = append(oldPaths, sortedKeys(sources)...)
+	}
+	oldEntry := func(filePath string) TreeEntry {
+		if entry, isDeleted := deleted[filePath]; isDeleted {
+			return entry
+		}
+		return sources[filePath]
+	}
+	newPaths := sortedKeys(added)
+
+	var pairs []renamePair
+	usedDeleted := make(map[string]bool)
+	paired := make(map[string]bool)
+	assign := func(from, to string, score int) {
+		_, isDeleted := deleted[from]
+		switch {
+		case isDeleted && !usedDeleted[from]:
+			usedDeleted[from] = true
+			pairs = append(pairs, renamePair{from: from, to: to, score: score})
+		case opts.Copies:
+			pairs = append(pairs, renamePair{from: from, to: to, score: score, copy: true})
+		default:
+			return
+		}
+		paired[to] = true
+	}
+
+	// 1. Exact renames: same blob, preferring a source with the same name.
+	byHash := make(map[string][]string)
+	for _, from := range oldPaths {
+		if entry := oldEntry(from); entry.Hash != EmptyBlobHash {
+			byHash[entry.Hash] = append(byHash[entry.Hash], from)
+		}
+	}
+	for _, to := range newPaths {
+		candidates := byHash[added[to].Hash]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return path.Base(candidates[i]) == path.Base(to) && path.Base(candidates[j]) != path.Base(to)
+		})
+		for _, from := range candidates {
+			if _, isDeleted := deleted[from]; (isDeleted && !usedDeleted[from]) || opts.Copies {
+				assign(from, to, 100)
+				break
+			}
+		}
+	}
+
+	// 2. Inexact renames, by similarity of content.
+	var remainingOld, remainingNew []string
+	for _, from := range oldPaths {
+		if !usedDeleted[from] || opts.Copies {
+			remainingOld = append(remainingOld, from)
+		}
+	}
+	for _, to := range newPaths {
+		if !paired[to] {
+			remainingNew = append(remainingNew, to)
+		}
+	}
+	if len(remainingOld) == 0 || len(remainingNew) == 0 {
+		return sortPairs(pairs), nil
+	}
+	if len(remainingOld) > renameLimit || len(remainingNew) > renameLimit {
+		fmt.Fprintln(os.Stderr, "warning: exhaustive rename detection was skipped due to too many files.")
+		return sortPairs(pairs), nil
+	}
+
+	oldSignatures := make(map[string]*similarity, len(remainingOld))
+	for _, from := range remainingOld {
+		content, err := loadOld(from)
+		if err != nil {
+			return nil, err
+		}
+		oldSignatures[from] = newSimilarity(content)
+	}
+
+	type candidate struct {
+		from, to string
+		score    int
+		sameName bool
+	}
+	var candidates []candidate
+	for _, to := range remainingNew {
+		content, err := loadNew(to)
+		if err != nil {
+			return nil, err
+		}
+		newSignature := newSimilarity(content)
+		for _, from := range remainingOld {
+			score := oldSignatures[from].score(newSignature, opts.Threshold)
+			if score >= opts.Threshold && score > 0 {
+				candidates = append(candidates, candidate{from, to, score, path.Base(from) == path.Base(to)})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.sameName != b.sameName {
+			return a.sameName
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		return a.from < b.from
+	})
+	for _, c := range candidates {
+		if paired[c.to] {
+			continue
+		}
+		if _, isDeleted := deleted[c.from]; isDeleted && usedDeleted[c.from] && !opts.Copies {
+			continue
+		}
+		assign(c.from, c.to, c.score)
+	}
+
+	return sortPairs(pairs), nil
+}
+
+// sortPairs orders the pairs by destination.
+func sortPairs(pairs []renamePair) []renamePair {
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].to < pairs[j].to
+	})
+	return pairs
+}
+
+// sortedKeys returns the paths of a tree map in order.
+func sortedKeys(entries map[string]TreeEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// similarity summarizes a file for comparison: its content cut into lines
+// (or pieces of at most similarityChunk bytes) and the number of bytes of
+// each distinct piece.
+type similarity struct {
+	size   int
+	chunks map[uint64]int
+}
+
+func newSimilarity(content []byte) *similarity {
+	s := &similarity{size: len(content), chunks: make(map[uint64]int)}
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, '\n') + 1
+		if end == 0 || end > similarityChunk {
+			end = min(len(content), similarityChunk)
+		}
+
+		hash := fnv.New64a()
+		hash.Write(content[:end])
+		s.chunks[hash.Sum64()] += end
+		content = content[end:]
+	}
+	return s
+}
+
+// score returns the similarity of two files in percent: the bytes they have
+// in common over the size of the larger one. Files too different in size to
+// reach threshold are not compared.
+func (s *similarity) score(other *similarity, threshold int) int {
+	larger, smaller := max(s.size, other.size), min(s.size, other.size)
+	if larger == 0 || smaller*100 < threshold*larger {
+		return 0
+	}
+
+	common := 0
+	for hash, count := range s.chunks {
+		if otherCount, found := other.chunks[hash]; found {
+			common += min(count, otherCount)
+		}
+	}
+	return common * 100 / larger
+}
+
+// fileChange is one path changed between two snapshots.
+type fileChange struct {
+	status   byte   // one of the Status letters, except untracked
+	path     string // path on the new side (the old one for deletions)
+	origPath string // source of a rename or copy, empty otherwise
+	score    int    // similarity of a rename or copy, in percent
+	old, new *TreeEntry
+}
+
+// nameStatus formats the change like `--name-status`: the letter (with the
+// score for renames and copies) and the paths, separated by tabs.
+func (c fileChange) nameStatus() string {
+	if c.origPath == "" {
+		return fmt.Sprintf("%c\t%s", c.status, quotePath(c.path))
+	}
+	return fmt.Sprintf("%c%03d\t%s\t%s", c.status, c.score, quotePath(c.origPath), quotePath(c.path))
+}
+
+// diffTrees lists the changes from oldTree to newTree, sorted by path, with
+// renames and copies paired as opts asks. The old blobs are read from the
+// object store; loadNew reads a file of the new side, nil meaning the object
+// store as well.
+func diffTrees(oldTree, newTree map[string]TreeEntry, opts RenameOptions, loadNew func(filePath string) ([]byte, error)) ([]fileChange, error) {
+	var changes []fileChange
+	deleted := make(map[string]TreeEntry)
+	added := make(map[string]TreeEntry)
+	modified := make(map[string]TreeEntry)
+
+	for _, filePath := range ChangedPaths(oldTree, newTree) {
+		oldEntry, inOld := oldTree[filePath]
+		newEntry, inNew := newTree[filePath]
+		switch {
+		case !inNew:
+			deleted[filePath] = oldEntry
+		case !inOld:
+			added[filePath] = newEntry
+		default:
+			modified[filePath] = oldEntry
+			changes = append(changes, fileChange{
+				status: changeStatus(oldEntry, newEntry),
+				path:   filePath,
+				old:    &oldEntry,
+				new:    &newEntry,
+			})
+		}
+	}
+
+	loadOld := func(filePath string) ([]byte, error) {
+		return readObjectContent(oldTree[filePath].Hash)
+	}
+	if loadNew == nil {
+		loadNew = func(filePath string) ([]byte, error) {
+			return readObjectContent(newTree[filePath].Hash)
+		}
+	}
+	pairs, err := detectRenames(deleted, added, modified, opts, loadOld, loadNew)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range pairs {
+		oldEntry, newEntry := oldTree[pair.from], newTree[pair.to]
+		status := byte(StatusRenamed)
+		if pair.copy {
+			status = StatusCopied
+		} else {
+			delete(deleted, pair.from)
+		}
+		delete(added, pair.to)
+		changes = append(changes, fileChange{
+			status:   status,
+			path:     pair.to,
+			origPath: pair.from,
+			score:    pair.score,
+			old:      &oldEntry,
+			new:      &newEntry,
+		})
+	}
+	for filePath, entry := range deleted {
+		changes = append(changes, fileChange{status: StatusDeleted, path: filePath, old: &entry})
+	}
+	for filePath, entry := range added {
+		changes = append(changes, fileChange{status: StatusAdded, path: filePath, new: &entry})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes, nil
+}
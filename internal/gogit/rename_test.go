@@ -0,0 +1,248 @@
+package gogit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns count lines of ten bytes, from "line 0000\n" on,
+// with the lines listed in changed replaced.
+func numberedLines(count int, changed ...int) string {
+	var builder strings.Builder
+	for i := range count {
+		prefix := "line"
+		for _, c := range changed {
+			if c == i {
+				prefix = "LINE"
+			}
+		}
+		fmt.Fprintf(&builder, "%s %04d\n", prefix, i)
+	}
+	return builder.String()
+}
+
+func TestParseRenameThreshold(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: DefaultRenameThreshold},
+		{value: "90%", want: 90},
+		{value: "100%", want: 100},
+		{value: "0%", want: 0},
+		{value: "9", want: 90},
+		{value: "90", want: 90},
+		{value: "5", want: 50},
+		{value: "05", want: 5},
+		{value: "999", want: 100},
+		{value: "101%", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "+5", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "5%%", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRenameThreshold(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRenameThreshold(%q) = %d, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRenameThreshold(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestSimilarityScore(t *testing.T) {
+	long := strings.Repeat("0123456789", 20)
+	tests := []struct {
+		name      string
+		a, b      string
+		threshold int
+		want      int
+	}{
+		{"identical", numberedLines(10), numberedLines(10), 50, 100},
+		{"both empty", "", "", 50, 0},
+		{"three lines changed", numberedLines(10), numberedLines(10, 1, 4, 7), 50, 70},
+		{"all lines changed", numberedLines(10), numberedLines(10, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9), 0, 0},
+		{"lines added", numberedLines(8), numberedLines(10), 50, 80},
+		// Files too different in size are not even compared.
+		{"size below threshold", numberedLines(10), numberedLines(3), 50, 0},
+		{"size above threshold", numberedLines(10), numberedLines(3), 20, 30},
+		// Long lines are cut into 64 byte chunks, so a change only costs the
+		// chunks it touches: here the last two.
+		{"long line", long, long[:190] + "xxxxxxxxxx", 50, 64},
+	}
+	for _, tt := range tests {
+		got := newSimilarity([]byte(tt.a)).score(newSimilarity([]byte(tt.b)), tt.threshold)
+		if got != tt.want {
+			t.Errorf("%s: score = %d, want %d", tt.name, got, tt.want)
+		}
+		// The score does not depend on the order of the files.
+		if back := newSimilarity([]byte(tt.b)).score(newSimilarity([]byte(tt.a)), tt.threshold); back != got {
+			t.Errorf("%s: reversed score = %d, want %d", tt.name, back, got)
+		}
+	}
+}
+
+func TestDetectRenames(t *testing.T) {
+	contents := map[string]string{
+		"h1":          numberedLines(10),
+		"h2":          numberedLines(10, 1, 4, 7),
+		"h3":          numberedLines(10, 0, 2, 4, 6, 8),
+		"h4":          "unrelated\n",
+		EmptyBlobHash: "",
+	}
+	entries := func(pathHashes ...string) map[string]TreeEntry {
+		tree := make(map[string]TreeEntry)
+		for i := 0; i < len(pathHashes); i += 2 {
+			tree[pathHashes[i]] = TreeEntry{Mode: ModeRegular, Hash: pathHashes[i+1]}
+		}
+		return tree
+	}
+	renames := RenameOptions{Renames: true, Threshold: DefaultRenameThreshold}
+	copies := RenameOptions{Renames: true, Copies: true, Threshold: DefaultRenameThreshold}
+
+	tests := []struct {
+		name    string
+		deleted map[string]TreeEntry
+		added   map[string]TreeEntry
+		sources map[string]TreeEntry
+		opts    RenameOptions
+		want    []renamePair
+	}{
+		{
+			name:    "disabled",
+			deleted: entries("a", "h1"),
+			added:   entries("b", "h1"),
+			opts:    RenameOptions{Threshold: DefaultRenameThreshold},
+		},
+		{
+			name:    "exact rename",
+			deleted: entries("a", "h1"),
+			added:   entries("b", "h1"),
+			opts:    renames,
+			want:    []renamePair{{from: "a", to: "b", score: 100}},
+		},
+		{
+			name:    "exact rename prefers the same name",
+			deleted: entries("x/f.txt", "h1", "y/g.txt", "h1"),
+			added:   entries("z/f.txt", "h1"),
+			opts:    renames,
+			want:    []renamePair{{from: "x/f.txt", to: "z/f.txt", score: 100}},
+		},
+		{
+			name:    "a deleted file is renamed once",
+			deleted: entries("a", "h1"),
+			added:   entries("b", "h1", "c", "h1"),
+			opts:    renames,
+			want:    []renamePair{{from: "a", to: "b", score: 100}},
+		},
+		{
+			name:    "inexact rename",
+			deleted: entries("old.txt", "h1"),
+			added:   entries("new.txt", "h2"),
+			opts:    renames,
+			want:    []renamePair{{from: "old.txt", to: "new.txt", score: 70}},
+		},
+		{
+			name:    "inexact rename below the threshold",
+			deleted: entries("old.txt", "h1"),
+			added:   entries("new.txt", "h2"),
+			opts:    RenameOptions{Renames: true, Threshold: 80},
+		},
+		{
+			name:    "most similar pair first",
+			deleted: entries("a", "h1", "b", "h3"),
+			added:   entries("c", "h2"),
+			opts:    renames,
+			want:    []renamePair{{from: "a", to: "c", score: 70}},
+		},
+		{
+			name:    "unrelated files",
+			deleted: entries("a", "h1"),
+			added:   entries("b", "h4"),
+			opts:    renames,
+		},
+		{
+			name:    "empty files are not paired",
+			deleted: entries("a", EmptyBlobHash),
+			added:   entries("b", EmptyBlobHash),
+			opts:    renames,
+		},
+		{
+			name:    "copies need -C",
+			added:   entries("copy", "h1"),
+			sources: entries("orig", "h1"),
+			opts:    renames,
+		},
+		{
+			name:    "exact copy",
+			added:   entries("copy", "h1"),
+			sources: entries("orig", "h1"),
+			opts:    copies,
+			want:    []renamePair{{from: "orig", to: "copy", score: 100, copy: true}},
+		},
+		{
+			name:    "inexact copy",
+			added:   entries("copy", "h2"),
+			sources: entries("orig", "h1"),
+			opts:    copies,
+			want:    []renamePair{{from: "orig", to: "copy", score: 70, copy: true}},
+		},
+		{
+			name:    "rename then copy of the same file",
+			deleted: entries("a", "h1"),
+			added:   entries("b", "h1", "c", "h1"),
+			opts:    copies,
+			want: []renamePair{
+				{from: "a", to: "b", score: 100},
+				{from: "a", to: "c", score: 100, copy: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		side := func(tree map[string]TreeEntry) func(string) ([]byte, error) {
+			return func(filePath string) ([]byte, error) {
+				return []byte(contents[tree[filePath].Hash]), nil
+			}
+		}
+		loadOld := func(filePath string) ([]byte, error) {
+			if _, isDeleted := tt.deleted[filePath]; isDeleted {
+				return side(tt.deleted)(filePath)
+			}
+			return side(tt.sources)(filePath)
+		}
+
+		got, err := detectRenames(tt.deleted, tt.added, tt.sources, tt.opts, loadOld, side(tt.added))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: detectRenames() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileChangeNameStatus(t *testing.T) {
+	tests := []struct {
+		change fileChange
+		want   string
+	}{
+		{fileChange{status: StatusModified, path: "a.txt"}, "M\ta.txt"},
+		{fileChange{status: StatusRenamed, path: "new.txt", origPath: "old.txt", score: 70}, "R070\told.txt\tnew.txt"},
+		{fileChange{status: StatusCopied, path: "copy", origPath: "orig", score: 100}, "C100\torig\tcopy"},
+	}
+	for _, tt := range tests {
+		if got := tt.change.nameStatus(); got != tt.want {
+			t.Errorf("nameStatus() = %q, want %q", got, tt.want)
+		}
+	}
+}
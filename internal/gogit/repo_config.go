@@ -0,0 +1,111 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Configuration variables are named like Git's: "core.workers" is the key
+// "workers" of the [core] section and "branch.main.remote" is the key
+// "remote" of the [branch "main"] section. The repository configuration
+// (.gogit/config) takes precedence over the global one (~/.gogitconfig).
+
+// splitConfigName turns a variable name into its ini section and key.
+func splitConfigName(name string) (section, key string, err error) {
+	first := strings.IndexByte(name, '.')
+	last := strings.LastIndexByte(name, '.')
+	if first <= 0 || last == len(name)-1 {
+		return "", "", fmt.Errorf("error: key does not contain a section: %s", name)
+	}
+
+	key = strings.ToLower(name[last+1:])
+	section = strings.ToLower(name[:first])
+	if first != last {
+		section = fmt.Sprintf("%s \"%s\"", section, name[first+1:last])
+	}
+	return section, key, nil
+}
+
+// loadConfigFile parses a configuration file. A missing file is empty.
+func loadConfigFile(path string) (*ini.File, error) {
+	cfg, err := ini.LoadSources(ini.LoadOptions{
+		AllowBooleanKeys:    true,
+		IgnoreInlineComment: true,
+		InsensitiveKeys:     true,
+		Loose:               true,
+	}, path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ConfigValue returns the value of a configuration variable and whether it
+// is set, looking at the repository configuration first.
+func ConfigValue(name string) (string, bool, error) {
+	section, key, err := splitConfigName(name)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, path := range []string{RepoConfigPath, os.ExpandEnv("$HOME/" + GLOBAL_CONFIG)} {
+		cfg, err := loadConfigFile(path)
+		if err != nil {
+			return "", false, err
+		}
+		if sec, err := cfg.GetSection(section); err == nil && sec.HasKey(key) {
+			return sec.Key(key).String(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// SetRepoConfig sets a variable in the repository configuration.
+func SetRepoConfig(name, value string) error {
+	return updateRepoConfig(name, func(sec *ini.Section, key string) {
+		sec.Key(key).SetValue(value)
+	})
+}
+
+// UnsetRepoConfig removes a variable from the repository configuration.
+func UnsetRepoConfig(name string) error {
+	return updateRepoConfig(name, func(sec *ini.Section, key string) {
+		sec.DeleteKey(key)
+	})
+}
+
+// updateRepoConfig applies change to the section of name while holding the
+// lock of the repository configuration. Sections left empty are dropped.
+func updateRepoConfig(name string, change func(sec *ini.Section, key string)) error {
+	section, key, err := splitConfigName(name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := AcquireLock(RepoConfigPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	cfg, err := loadConfigFile(RepoConfigPath)
+	if err != nil {
+		return err
+	}
+
+	sec := cfg.Section(section)
+	change(sec, key)
+	if len(sec.Keys()) == 0 {
+		cfg.DeleteSection(section)
+	}
+
+	var buf bytes.Buffer
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return fmt.Errorf("error writing %s: %w", RepoConfigPath, err)
+	}
+	return lock.Commit(buf.Bytes())
+}
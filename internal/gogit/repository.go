@@ -0,0 +1,237 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitdirPrefix starts the .gogit file of a linked worktree.
+const gitdirPrefix = "gogitdir:"
+
+// prefix is the directory the command was run from, relative to the root of
+// the working tree: "" at the root, "src/lib" in src/lib. Once the
+// repository is set up the current directory is the root, and paths given
+// on the command line are resolved against prefix.
+var prefix string
+
+// bareRepository is set when the repository has no working tree; the
+// current directory is then left as it is.
+var bareRepository bool
+
+// SetupRepository finds the repository and the root of its working tree, and
+// makes the root the current directory.
+//
+// GOGIT_DIR names the repository; the working tree is then GOGIT_WORK_TREE,
+// or the current directory unless the repository is bare (core.bare).
+// Otherwise .gogit is looked for in the current directory and its parents,
+// and its directory is the root of the working tree unless GOGIT_WORK_TREE
+// says otherwise. .gogit is either the repository itself or, in a linked
+// worktree, a file "gogitdir: <path>" naming the directory of the worktree
+// inside the repository (.gogit/worktrees/<name>), whose commondir file
+// leads back to the repository. A directory that is itself a repository,
+// such as one made by "init --bare", is used without a working tree.
+//
+// Without a repository nothing changes, and the commands that need one fail
+// on their own.
+func SetupRepository() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	gitDir := os.Getenv("GOGIT_DIR")
+	workTree := os.Getenv("GOGIT_WORK_TREE")
+	if gitDir == "" {
+		root, repo, found := findRepository(cwd)
+		if !found {
+			return nil
+		}
+		gitDir = repo
+		if workTree == "" {
+			workTree = root
+		}
+	} else if workTree == "" && !isBareRepository(gitDir) {
+		workTree = cwd
+	}
+	if gitDir, err = filepath.Abs(gitDir); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(gitDir); err == nil && !info.IsDir() {
+		if gitDir, err = readGitdirFile(gitDir); err != nil {
+			return err
+		}
+	}
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("fatal: not a gogit repository: %s", gitDir)
+	}
+
+	commonDir := gitDir
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	// A bare repository is used from where the command runs, so that the
+	// paths it is given (such as the one of "worktree add") keep their
+	// meaning.
+	prefix = ""
+	bareRepository = workTree == ""
+	if bareRepository {
+		setRepoPaths(gitDir, filepath.Clean(commonDir))
+		return nil
+	}
+	if workTree, err = filepath.Abs(workTree); err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(workTree, cwd); err == nil && !isOutside(filepath.ToSlash(rel)) && rel != "." {
+		prefix = filepath.ToSlash(rel)
+	}
+	if err := os.Chdir(workTree); err != nil {
+		return fmt.Errorf("fatal: cannot chdir to '%s': %w", workTree, err)
+	}
+
+	setRepoPaths(relativeToRoot(workTree, gitDir), relativeToRoot(workTree, filepath.Clean(commonDir)))
+	return nil
+}
+
+// findRepository looks for the repository in dir and its parents. The
+// closest directory holding .gogit is the root of a working tree; a
+// directory that is a repository itself has none, and root is empty.
+func findRepository(dir string) (root, gitDir string, found bool) {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ROOT)); err == nil {
+			return dir, filepath.Join(dir, ROOT), true
+		}
+		if isRepositoryDir(dir) {
+			return "", dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// isRepositoryDir reports whether dir has the HEAD, objects and refs of a
+// repository.
+func isRepositoryDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, HEAD)); err != nil || info.IsDir() {
+		return false
+	}
+	for _, sub := range []string{OBJECTS, "refs"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// isBareRepository reports whether the configuration of the repository in
+// gitDir sets core.bare.
+func isBareRepository(gitDir string) bool {
+	cfg, err := loadConfigFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return false
+	}
+	sec, err := cfg.GetSection("core")
+	if err != nil || !sec.HasKey("bare") {
+		return false
+	}
+	bare, err := strconv.ParseBool(sec.Key("bare").String())
+	return err == nil && bare
+}
+
+// RequireWorktree fails in a bare repository, for the commands that read or
+// write the working tree or the index.
+func RequireWorktree() error {
+	if bareRepository {
+		return fmt.Errorf("fatal: this operation must be run in a work tree")
+	}
+	return nil
+}
+
+// relativeToRoot keeps the paths inside the working tree relative, so that
+// the repository of a plain checkout is still ".gogit".
+func relativeToRoot(root, dir string) string {
+	if rel, err := filepath.Rel(root, dir); err == nil && !isOutside(filepath.ToSlash(rel)) {
+		return rel
+	}
+	return dir
+}
+
+// readGitdirFile returns the directory named by a .gogit file, relative
+// paths being relative to the file.
+func readGitdirFile(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", filePath, err)
+	}
+
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(content)), gitdirPrefix)
+	gitDir = strings.TrimSpace(gitDir)
+	if !found || gitDir == "" {
+		return "", fmt.Errorf("fatal: invalid gitfile format: %s", filePath)
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(filePath), gitDir)
+	}
+	return gitDir, nil
+}
+
+// UserPath returns the path to use for a path given on the command line,
+// which is relative to the directory the command was run from.
+func UserPath(arg string) string {
+	if filepath.IsAbs(arg) {
+		return arg
+	}
+	return filepath.Join(filepath.FromSlash(prefix), arg)
+}
+
+// rootPath returns the slash-separated path, relative to the root of the
+// working tree, of a path given on the command line. ok is false when the
+// path is outside the working tree; the root itself is ".".
+func rootPath(arg string) (p string, ok bool) {
+	full := UserPath(arg)
+	if filepath.IsAbs(full) {
+		root, err := os.Getwd()
+		if err != nil {
+			return "", false
+		}
+		if full, err = filepath.Rel(root, full); err != nil {
+			return "", false
+		}
+	}
+	p = path.Clean(filepath.ToSlash(full))
+	return p, !isOutside(p)
+}
+
+// isOutside reports whether a cleaned relative path leaves its directory.
+func isOutside(p string) bool {
+	return p == ".." || strings.HasPrefix(p, "../")
+}
+
+// displayPath returns a path relative to the root of the working tree as a
+// path relative to the directory the command was run from. A trailing slash
+// is kept.
+func displayPath(p string) string {
+	if prefix == "" {
+		return p
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(p))
+	if err != nil {
+		return p
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(p, "/") {
+		rel += "/"
+	}
+	return rel
+}
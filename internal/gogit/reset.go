@@ -0,0 +1,234 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ResetMode selects how much of the repository Reset rewinds.
+type ResetMode int
+
+const (
+	// ResetMixed moves the branch and resets the index (the default).
+	ResetMixed ResetMode = iota
+	// ResetSoft only moves the branch.
+	ResetSoft
+	// ResetHard moves the branch and resets both the index and the working tree.
+	ResetHard
+)
+
+// Reset moves the current branch to rev and, depending on mode, resets the
+// index and the working tree to the tree of that commit. On a branch without
+// commits, HEAD stands for the empty tree and the branch is left unborn.
+func Reset(rev string, mode ResetMode) error {
+	var targetHash string
+	var targetCommit *Commit
+	targetTree := make(map[string]TreeEntry)
+
+	unborn := false
+	if rev == HEAD {
+		head, err := ReadHead()
+		if err != nil {
+			return err
+		}
+		unborn = head.Hash == ""
+	}
+	if !unborn {
+		var err error
+		if targetHash, err = ResolveRevision(rev); err != nil {
+			return err
+		}
+		if targetCommit, err = ReadCommit(targetHash); err != nil {
+			return err
+		}
+		if targetCommit.Tree == "" {
+			return fmt.Errorf("fatal: '%s' is not a commit", rev)
+		}
+		if targetTree, err = ReadTree(targetCommit.Tree); err != nil {
+			return err
+		}
+	}
+
+	// Gather what is currently tracked before anything changes.
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+	headTree, err := ReadHeadTree()
+	if err != nil {
+		return fmt.Errorf("reading HEAD tree: %w", err)
+	}
+	sparse, err := ReadSparseCheckout()
+	if err != nil {
+		return err
+	}
+
+	if mode == ResetHard {
+		if err := resetWorktree(indexEntries, headTree, targetTree, sparse); err != nil {
+			return err
+		}
+	}
+
+	newIndex := TreeToIndex(targetTree)
+	sparse.markSkipWorktree(newIndex)
+	keepIndexFlags(newIndex, indexEntries)
+	if mode != ResetSoft {
+		if err := WriteIndex(indexLock, newIndex); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
+	}
+
+	if unborn {
+		if mode == ResetMixed {
+			printUnstagedAfterReset(newIndex)
+		}
+		return nil
+	}
+	if err := UpdateBranchRef(targetHash); err != nil {
+		return err
+	}
+
+	switch mode {
+	case ResetHard:
+		fmt.Printf("HEAD is now at %s %s\n", ShortHash(targetHash), firstLine(targetCommit.Message))
+	case ResetMixed:
+		printUnstagedAfterReset(newIndex)
+	}
+
+	return nil
+}
+
+// ResetPaths copies the entries matched by paths from the tree of rev into the
+// index, leaving the branch and the working tree alone. This is how files are
+// unstaged with `gogit reset <file>`.
+func ResetPaths(rev string, paths []string) error {
+	// On a branch without commits HEAD has an empty tree, so resetting paths
+	// to it simply unstages them.
+	readTarget := ReadCommitTree
+	if rev == HEAD {
+		readTarget = func(string) (map[string]TreeEntry, error) { return ReadHeadTree() }
+	}
+
+	targetTree, err := readTarget(rev)
+	if err != nil {
+		return err
+	}
+
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	spec, err := ParsePathspec(paths)
+	if err != nil {
+		return err
+	}
+
+	for indexPath := range indexEntries {
+		if _, inTarget := targetTree[indexPath]; !inTarget && spec.Match(indexPath) {
+			delete(indexEntries, indexPath)
+		}
+	}
+	for treePath, entry := range targetTree {
+		if spec.Match(treePath) {
+			indexEntries[treePath] = IndexEntry{Mode: entry.Mode, Hash: entry.Hash}
+		}
+	}
+
+	if err := spec.CheckUnmatched(); err != nil {
+		return err
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+
+	return nil
+}
+
+// resetWorktree makes the working tree match targetTree: every tracked file
+// that differs from the target is overwritten, even with local changes, and
+// tracked files absent from the target are deleted. Paths outside a sparse
+// checkout are left out.
+func resetWorktree(indexEntries map[string]IndexEntry, headTree, targetTree map[string]TreeEntry, sparse *SparseCheckout) error {
+	tracked := make(map[string]bool)
+	for path := range indexEntries {
+		tracked[path] = true
+	}
+	for path := range headTree {
+		tracked[path] = true
+	}
+
+	// Describe the working tree as it really is, so that ApplyDiffCheckout
+	// rewrites every file whose content differs from the target.
+	currentWorktree := make(map[string]TreeEntry)
+	for path := range tracked {
+		entry, err := hashWorktreeFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		currentWorktree[path] = entry
+	}
+
+	if err := ApplyDiffCheckout(currentWorktree, targetTree, sparse); err != nil {
+		return fmt.Errorf("error resetting working tree: %w", err)
+	}
+
+	for path := range currentWorktree {
+		if _, inTarget := targetTree[path]; !inTarget {
+			removeEmptyParents(path)
+		}
+	}
+
+	return nil
+}
+
+// printUnstagedAfterReset lists the tracked files whose working tree content
+// no longer matches the freshly reset index.
+func printUnstagedAfterReset(indexEntries map[string]IndexEntry) {
+	var lines []string
+	for path, indexEntry := range indexEntries {
+		if indexEntry.ignoresWorktree() {
+			continue
+		}
+		workdirEntry, err := hashWorktreeFile(path)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("D\t%s", path))
+		} else if workdirEntry.Hash != indexEntry.Hash || workdirEntry.Mode != indexEntry.Mode {
+			lines = append(lines, fmt.Sprintf("M\t%s", path))
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	fmt.Println("Unstaged changes after reset:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+// firstLine returns the subject line of a commit message.
+func firstLine(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	return subject
+}
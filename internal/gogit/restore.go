@@ -0,0 +1,135 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// RestoreOptions controls what Restore overwrites and where the content
+// comes from (equivalent to `git restore`).
+type RestoreOptions struct {
+	// Staged restores the index entries.
+	Staged bool
+	// Worktree restores the files in the working tree. It is the default
+	// when neither Staged nor Worktree is set.
+	Worktree bool
+	// Source is the revision to restore from. It defaults to HEAD when
+	// Staged is set and to the index otherwise.
+	Source string
+}
+
+// Restore overwrites the index and/or the working tree copies of the files
+// matched by paths with their content in the restore source. Files that do
+// not exist in the source are removed.
+func Restore(paths []string, opts RestoreOptions) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("fatal: you must specify path(s) to restore")
+	}
+	if !opts.Staged && !opts.Worktree {
+		opts.Worktree = true
+	}
+	if opts.Source == "" && opts.Staged {
+		opts.Source = HEAD
+	}
+
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	// 1. Load the source: the index itself or the tree of a commit.
+	var sourceEntries map[string]TreeEntry
+	switch opts.Source {
+	case "":
+		sourceEntries = IndexToTree(indexEntries)
+	case HEAD:
+		// Before the first commit HEAD has an empty tree.
+		if sourceEntries, err = ReadHeadTree(); err != nil {
+			return err
+		}
+	default:
+		sourceEntries, err = ReadCommitTree(opts.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2. Resolve the pathspecs against the source and the current index.
+	spec, err := ParsePathspec(paths)
+	if err != nil {
+		return err
+	}
+
+	matched := make(map[string]bool)
+	for sourcePath := range sourceEntries {
+		if spec.Match(sourcePath) {
+			matched[sourcePath] = true
+		}
+	}
+	for indexPath := range indexEntries {
+		if spec.Match(indexPath) {
+			matched[indexPath] = true
+		}
+	}
+
+	if unmatched := spec.Unmatched(); len(unmatched) > 0 {
+		// "restore <rev> <path>" takes the revision for a path.
+		if unmatched[0] == paths[0] && len(paths) > 1 {
+			if _, err := ResolveRevision(paths[0]); err == nil {
+				return fmt.Errorf("fatal: '%s' is a revision, not a path; use --source=%s to restore from it", paths[0], paths[0])
+			}
+		}
+		return fmt.Errorf("error: pathspec '%s' did not match any file(s) known to gogit", unmatched[0])
+	}
+
+	var sortedPaths []string
+	for path := range matched {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	// 3. Restore the working tree, leaving files that already match untouched.
+	if opts.Worktree {
+		for _, path := range sortedPaths {
+			sourceEntry, inSource := sourceEntries[path]
+			if !inSource {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("error deleting file %s: %w", path, err)
+				}
+				removeEmptyParents(path)
+				continue
+			}
+
+			if current, err := hashWorktreeFile(path); err == nil && current == sourceEntry {
+				continue
+			}
+			if err := writeWorktreeFile(path, sourceEntry); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 4. Restore the index.
+	if opts.Staged {
+		for _, path := range sortedPaths {
+			if sourceEntry, inSource := sourceEntries[path]; inSource {
+				indexEntries[path] = IndexEntry{Mode: sourceEntry.Mode, Hash: sourceEntry.Hash}
+			} else {
+				delete(indexEntries, path)
+			}
+		}
+
+		if err := WriteIndex(indexLock, indexEntries); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
+	}
+
+	return nil
+}
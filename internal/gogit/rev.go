@@ -0,0 +1,124 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ResolveRevision turns a revision into a commit hash. It understands "HEAD",
+// branch names, full or abbreviated (at least 4 characters) commit hashes and
+// any number of "~<n>" and "^" suffixes to walk up the first-parent chain.
+func ResolveRevision(rev string) (string, error) {
+	unknown := fmt.Errorf("fatal: ambiguous argument '%s': unknown revision or path not in the working tree", rev)
+
+	base := rev
+	suffix := ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+
+	hash, err := resolveRevisionBase(base)
+	if err != nil {
+		return "", err
+	}
+	if hash == "" {
+		return "", unknown
+	}
+
+	// Walk the suffixes: "^" and "~" alone mean one generation, "~<n>" means n.
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		generations := 1
+		if op == '~' {
+			digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+			if digits > 0 {
+				generations, _ = strconv.Atoi(suffix[:digits])
+				suffix = suffix[digits:]
+			}
+		}
+
+		for range generations {
+			commit, err := ReadCommit(hash)
+			if err != nil {
+				return "", err
+			}
+			if commit.Parent == "" {
+				return "", unknown
+			}
+			hash = commit.Parent
+		}
+	}
+
+	return hash, nil
+}
+
+// resolveRevisionBase resolves a revision without suffixes. It returns an
+// empty hash when nothing matches.
+func resolveRevisionBase(name string) (string, error) {
+	if name == HEAD || name == "@" {
+		return GetBranchHash()
+	}
+
+	if exists, err := CheckIfBranchExists(name); err != nil {
+		return "", err
+	} else if exists {
+		return GetTargetBranchHash(name)
+	}
+
+	return findObjectByPrefix(name)
+}
+
+// findObjectByPrefix looks up an object whose hash starts with prefix.
+func findObjectByPrefix(prefix string) (string, error) {
+	const minPrefixLen = 4
+	if len(prefix) < minPrefixLen || len(prefix) > 40 || strings.Trim(strings.ToLower(prefix), "0123456789abcdef") != "" {
+		return "", nil
+	}
+	prefix = strings.ToLower(prefix)
+
+	entries, err := os.ReadDir(filepath.Join(ObjectsPath, prefix[:2]))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading objects: %w", err)
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix[2:]) {
+			matches = append(matches, prefix[:2]+entry.Name())
+		}
+	}
+
+	if len(matches) > 1 {
+		return "", fmt.Errorf("fatal: short object ID %s is ambiguous", prefix)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0], nil
+}
+
+// ReadCommitTree resolves rev and returns the tree of that commit.
+func ReadCommitTree(rev string) (map[string]TreeEntry, error) {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	if commit.Tree == "" {
+		return nil, fmt.Errorf("fatal: '%s' is not a commit", rev)
+	}
+
+	return ReadTree(commit.Tree)
+}
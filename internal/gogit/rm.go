@@ -0,0 +1,146 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RemoveOptions controls the behaviour of Remove (equivalent to `git rm`).
+type RemoveOptions struct {
+	// Recursive allows removing every tracked file below a directory.
+	Recursive bool
+	// Cached only untracks the files and keeps them in the working tree.
+	Cached bool
+	// Force skips the safety checks against losing uncommitted content.
+	Force bool
+}
+
+// Remove untracks the files matched by the given paths and, unless
+// opts.Cached is set, deletes them from the working tree.
+func Remove(paths []string, opts RemoveOptions) error {
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	headTree, err := ReadHeadTree()
+	if err != nil {
+		return fmt.Errorf("reading HEAD tree: %w", err)
+	}
+
+	// 1. Resolve the pathspecs against the index.
+	spec, err := ParsePathspec(paths)
+	if err != nil {
+		return err
+	}
+
+	toRemove := make(map[string]bool)
+	for indexPath := range indexEntries {
+		item := spec.matchItem(indexPath)
+		if item == nil {
+			continue
+		}
+		if !opts.Recursive && item.isPrefixMatch() && indexPath != item.pattern {
+			return fmt.Errorf("fatal: not removing '%s' recursively without -r", item.original)
+		}
+		toRemove[indexPath] = true
+	}
+
+	if err := spec.CheckUnmatched(); err != nil {
+		return err
+	}
+
+	var sortedPaths []string
+	for path := range toRemove {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	// 2. Make sure no uncommitted content is lost, unless forced.
+	if !opts.Force {
+		if err := checkRemoveSafety(sortedPaths, indexEntries, headTree, opts.Cached); err != nil {
+			return err
+		}
+	}
+
+	// 3. Update the index and the working tree.
+	for _, path := range sortedPaths {
+		delete(indexEntries, path)
+
+		if !opts.Cached {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error deleting file %s: %w", path, err)
+			}
+			removeEmptyParents(path)
+		}
+
+		fmt.Printf("rm '%s'\n", path)
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+
+	return nil
+}
+
+// checkRemoveSafety mirrors the checks done by `git rm`: a file whose staged
+// content matches neither HEAD nor the working tree is never removed, and
+// without --cached a file with staged or local changes is kept as well.
+func checkRemoveSafety(paths []string, indexEntries map[string]IndexEntry, headTree map[string]TreeEntry, cached bool) error {
+	var stagedAndLocal, staged, local []string
+
+	for _, path := range paths {
+		indexEntry := indexEntries[path]
+		indexTreeEntry := TreeEntry{Mode: indexEntry.Mode, Hash: indexEntry.Hash}
+
+		headEntry, inHead := headTree[path]
+		stagedChanges := !inHead || headEntry != indexTreeEntry
+		if indexEntry.IntentToAdd {
+			// Nothing is staged, but any working tree content is local.
+			stagedChanges = false
+			indexTreeEntry.Hash = ""
+		}
+
+		localChanges := false
+		workdirEntry, err := hashWorktreeFile(path)
+		if err == nil {
+			localChanges = workdirEntry != indexTreeEntry
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		switch {
+		case stagedChanges && localChanges:
+			stagedAndLocal = append(stagedAndLocal, path)
+		case cached:
+			// Keeping the file in the working tree loses nothing.
+		case stagedChanges:
+			staged = append(staged, path)
+		case localChanges:
+			local = append(local, path)
+		}
+	}
+
+	switch {
+	case len(stagedAndLocal) > 0:
+		return fmt.Errorf("error: the following files have staged content different from both the\nfile and the HEAD:\n    %s\n"+
+			"(use -f to force removal)", strings.Join(stagedAndLocal, "\n    "))
+	case len(staged) > 0:
+		return fmt.Errorf("error: the following files have changes staged in the index:\n    %s\n"+
+			"(use --cached to keep the file, or -f to force removal)", strings.Join(staged, "\n    "))
+	case len(local) > 0:
+		return fmt.Errorf("error: the following files have local modifications:\n    %s\n"+
+			"(use --cached to keep the file, or -f to force removal)", strings.Join(local, "\n    "))
+	}
+
+	return nil
+}
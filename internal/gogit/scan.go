@@ -0,0 +1,113 @@
+package gogit
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// scanWorkers returns how many files are read and hashed in parallel: the
+// value of core.workers when it is set to a positive number, the number of
+// CPUs otherwise.
+func scanWorkers() (int, error) {
+	value, isSet, err := ConfigValue("core.workers")
+	if err != nil {
+		return 0, err
+	}
+	if !isSet {
+		return runtime.NumCPU(), nil
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 0 {
+		return 0, fmt.Errorf("fatal: bad numeric config value '%s' for 'core.workers'", value)
+	}
+	if workers == 0 {
+		return runtime.NumCPU(), nil
+	}
+	return workers, nil
+}
+
+// scanResult is the outcome of hashing one working tree file.
+type scanResult struct {
+	path  string
+	entry TreeEntry
+	err   error
+}
+
+// scanFiles reads and hashes the working tree files received on paths with a
+// pool of workers, until paths is closed. With writeObjects the blobs are
+// stored as well, as `add` needs.
+//
+// The results are keyed by path, so they do not depend on the order in which
+// the workers finish; files that could not be read are reported in failed.
+func scanFiles(paths <-chan string, writeObjects bool) (entries map[string]TreeEntry, failed map[string]error, err error) {
+	numWorkers, err := scanWorkers()
+	if err != nil {
+		// Drain the producer so that it does not block forever.
+		for range paths {
+		}
+		return nil, nil, err
+	}
+
+	results := make(chan scanResult, 100)
+	var wg sync.WaitGroup
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				results <- scanFile(path, writeObjects)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Single collector: the only goroutine touching the maps.
+	entries = make(map[string]TreeEntry)
+	failed = make(map[string]error)
+	for result := range results {
+		if result.err != nil {
+			failed[result.path] = result.err
+			continue
+		}
+		entries[result.path] = result.entry
+	}
+	return entries, failed, nil
+}
+
+// scanFile hashes a single file without following symlinks.
+func scanFile(path string, writeObjects bool) scanResult {
+	content, mode, err := readWorktreeBlob(path)
+	if err != nil {
+		return scanResult{path: path, err: fmt.Errorf("read: %w", err)}
+	}
+
+	var blobHash string
+	if writeObjects {
+		blobHash, err = writeBlob(content)
+	} else {
+		blobHash, _, err = HashObject(content)
+	}
+	if err != nil {
+		return scanResult{path: path, err: fmt.Errorf("hash: %w", err)}
+	}
+
+	return scanResult{path: path, entry: TreeEntry{Mode: mode, Hash: blobHash}}
+}
+
+// sortedFailures returns the paths of failed in order, so that errors are
+// reported the same way on every run.
+func sortedFailures(failed map[string]error) []string {
+	paths := make([]string, 0, len(failed))
+	for path := range failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
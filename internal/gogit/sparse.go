@@ -0,0 +1,302 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SparseCheckout describes which paths a sparse checkout keeps in the working
+// tree. Only cone mode is supported: the files at the top of the repository
+// are always present, and each listed directory is checked out recursively
+// together with the files directly inside its parent directories.
+//
+// The patterns are stored in .gogit/info/sparse-checkout in the same format
+// as Git's cone mode, for example for the directory "src/app":
+//
+//	/*
+//	!/*/
+//	/src/
+//	!/src/*/
+//	/src/app/
+//
+// A nil *SparseCheckout means the repository is not sparse.
+type SparseCheckout struct {
+	dirs []string // recursive directories, sorted and without overlaps
+}
+
+// ReadSparseCheckout loads the sparse-checkout patterns. It returns nil when
+// sparse checkout is not enabled.
+func ReadSparseCheckout() (*SparseCheckout, error) {
+	content, err := os.ReadFile(SparsePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", SparsePath, err)
+	}
+
+	var positive []string
+	parents := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line == "/*" || line == "!/*/":
+			// Top-level files are always part of the cone.
+		case strings.HasPrefix(line, "!/") && strings.HasSuffix(line, "/*/"):
+			parents[strings.TrimSuffix(strings.TrimPrefix(line, "!/"), "/*/")] = true
+		case strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && len(line) > 2:
+			positive = append(positive, strings.Trim(line, "/"))
+		default:
+			return nil, fmt.Errorf("fatal: unsupported pattern '%s' in %s: only cone mode is supported", line, SparsePath)
+		}
+	}
+
+	var dirs []string
+	for _, dir := range positive {
+		if !parents[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return &SparseCheckout{dirs: normalizeConeDirs(dirs)}, nil
+}
+
+// Includes reports whether path is checked out in the working tree.
+func (sc *SparseCheckout) Includes(filePath string) bool {
+	if sc == nil {
+		return true
+	}
+	dir := path.Dir(filePath)
+	if dir == "." {
+		return true
+	}
+	for _, coneDir := range sc.dirs {
+		// Everything inside a cone directory, and the files directly inside
+		// each of its parents.
+		if pathInScope(filePath, coneDir) || pathInScope(coneDir, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dirs returns the directories of the cone.
+func (sc *SparseCheckout) Dirs() []string {
+	if sc == nil {
+		return nil
+	}
+	return sc.dirs
+}
+
+// markSkipWorktree flags every index entry outside the sparse checkout as
+// skip-worktree.
+func (sc *SparseCheckout) markSkipWorktree(indexEntries map[string]IndexEntry) {
+	for path, entry := range indexEntries {
+		if !sc.Includes(path) {
+			entry.SkipWorktree = true
+			indexEntries[path] = entry
+		}
+	}
+}
+
+// normalizeConeDirs cleans directory arguments, sorts them and drops the ones
+// already inside another directory of the list.
+func normalizeConeDirs(dirs []string) []string {
+	var cleaned []string
+	for _, dir := range dirs {
+		dir = strings.Trim(path.Clean(filepath.ToSlash(dir)), "/")
+		if dir != "" && dir != "." {
+			cleaned = append(cleaned, dir)
+		}
+	}
+	sort.Strings(cleaned)
+
+	var result []string
+	for _, dir := range cleaned {
+		if len(result) > 0 && pathInScope(dir, result[len(result)-1]) {
+			continue
+		}
+		result = append(result, dir)
+	}
+	return result
+}
+
+// writeSparseCheckout stores the cone patterns for dirs.
+func writeSparseCheckout(dirs []string) error {
+	parents := make(map[string]bool)
+	for _, dir := range dirs {
+		for parent := path.Dir(dir); parent != "."; parent = path.Dir(parent) {
+			parents[parent] = true
+		}
+	}
+
+	names := append([]string{}, dirs...)
+	for parent := range parents {
+		names = append(names, parent)
+	}
+	sort.Strings(names)
+
+	lines := []string{"/*", "!/*/"}
+	for _, name := range names {
+		lines = append(lines, "/"+name+"/")
+		if parents[name] {
+			lines = append(lines, "!/"+name+"/*/")
+		}
+	}
+
+	infoDir := filepath.Dir(SparsePath)
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", infoDir, err)
+	}
+	return writeLockedFile(SparsePath, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// SparseCheckoutInit enables sparse checkout. A new cone only contains the
+// files at the top of the repository.
+func SparseCheckoutInit() error {
+	sc, err := ReadSparseCheckout()
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		if err := writeSparseCheckout(nil); err != nil {
+			return err
+		}
+		sc = &SparseCheckout{}
+	}
+	return updateSparseWorktree(sc)
+}
+
+// SparseCheckoutSet enables sparse checkout with exactly the given directories.
+func SparseCheckoutSet(dirs []string) error {
+	for _, dir := range dirs {
+		if err := checkConeDir(dir); err != nil {
+			return err
+		}
+	}
+
+	sc := &SparseCheckout{dirs: normalizeConeDirs(dirs)}
+	if err := writeSparseCheckout(sc.dirs); err != nil {
+		return err
+	}
+	return updateSparseWorktree(sc)
+}
+
+// SparseCheckoutAdd adds directories to the current cone.
+func SparseCheckoutAdd(dirs []string) error {
+	sc, err := ReadSparseCheckout()
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		return fmt.Errorf("fatal: no sparse-checkout to add to")
+	}
+	return SparseCheckoutSet(append(append([]string{}, sc.dirs...), dirs...))
+}
+
+// SparseCheckoutList prints the directories of the cone.
+func SparseCheckoutList() error {
+	sc, err := ReadSparseCheckout()
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		return fmt.Errorf("fatal: this worktree is not sparse")
+	}
+	for _, dir := range sc.Dirs() {
+		fmt.Println(dir)
+	}
+	return nil
+}
+
+// SparseCheckoutDisable restores every file in the working tree and turns
+// sparse checkout off.
+func SparseCheckoutDisable() error {
+	if err := updateSparseWorktree(nil); err != nil {
+		return err
+	}
+	if err := os.Remove(SparsePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing %s: %w", SparsePath, err)
+	}
+	return nil
+}
+
+// checkConeDir rejects directories that cannot be part of a cone.
+func checkConeDir(dir string) error {
+	cleaned := path.Clean(filepath.ToSlash(dir))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) {
+		return fmt.Errorf("fatal: '%s' is outside repository", dir)
+	}
+	if hasWildcard(cleaned) {
+		return fmt.Errorf("fatal: '%s' is not a directory; cone mode only accepts directories", dir)
+	}
+	return nil
+}
+
+// updateSparseWorktree makes the working tree follow sc: files entering the
+// cone are written from the index and files leaving it are removed and marked
+// skip-worktree. Files with local changes are never removed.
+func updateSparseWorktree(sc *SparseCheckout) error {
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	paths := make([]string, 0, len(indexEntries))
+	for path := range indexEntries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var notUpToDate []string
+	for _, path := range paths {
+		entry := indexEntries[path]
+		indexTreeEntry := TreeEntry{Mode: entry.Mode, Hash: entry.Hash}
+
+		switch included := sc.Includes(path); {
+		case included && entry.SkipWorktree:
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				if err := writeWorktreeFile(path, indexTreeEntry); err != nil {
+					return err
+				}
+			}
+			entry.SkipWorktree = false
+
+		case !included && !entry.SkipWorktree && !entry.IntentToAdd:
+			workdirEntry, err := hashWorktreeFile(path)
+			if err == nil && workdirEntry != indexTreeEntry {
+				notUpToDate = append(notUpToDate, path)
+				continue
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error reading %s: %w", path, err)
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error deleting file %s: %w", path, err)
+			}
+			removeEmptyParents(path)
+			entry.SkipWorktree = true
+		}
+		indexEntries[path] = entry
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+
+	if len(notUpToDate) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: The following paths are not up to date and were left despite sparse patterns:\n\t%s\n",
+			strings.Join(notUpToDate, "\n\t"))
+	}
+	return nil
+}
@@ -2,92 +2,528 @@ package gogit
 
 import (
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func StatusRepo() error {
-	ignorePatterns, err := readGogitignore()
+// StatusFormat selects how StatusRepo prints the status.
+type StatusFormat int
+
+const (
+	// StatusFormatLong is the human readable format (the default).
+	StatusFormatLong StatusFormat = iota
+	// StatusFormatShort prints one "XY path" line per entry (`--short`).
+	StatusFormatShort
+	// StatusFormatPorcelain is the short format without colors, stable for
+	// scripts (`--porcelain`).
+	StatusFormatPorcelain
+	// StatusFormatPorcelainV2 adds modes and hashes to each line
+	// (`--porcelain=v2`).
+	StatusFormatPorcelainV2
+)
+
+// StatusOptions controls the output of StatusRepo.
+type StatusOptions struct {
+	Format StatusFormat
+	// Branch prints the branch header in the short and porcelain formats.
+	Branch bool
+	// NullTerminated ends entries with NUL instead of LF and never quotes
+	// paths (`-z`).
+	NullTerminated bool
+	// Renames sets the detection of renamed and copied files among the
+	// staged changes.
+	Renames RenameOptions
+	// Untracked selects how untracked files are listed.
+	Untracked UntrackedMode
+	// Ignored lists the ignored files as well.
+	Ignored bool
+}
+
+// UntrackedMode selects how status lists untracked files
+// (`--untracked-files`).
+type UntrackedMode int
+
+const (
+	// UntrackedDefault uses status.showUntrackedFiles, "normal" when unset.
+	UntrackedDefault UntrackedMode = iota
+	// UntrackedNo shows no untracked files.
+	UntrackedNo
+	// UntrackedNormal shows a directory holding no tracked file as "dir/"
+	// instead of the files in it.
+	UntrackedNormal
+	// UntrackedAll shows every untracked file.
+	UntrackedAll
+)
+
+// ParseUntrackedMode parses "no", "normal" or "all". A boolean is accepted
+// too, for the configuration: true means "normal" and false "no".
+func ParseUntrackedMode(value string) (UntrackedMode, error) {
+	switch strings.ToLower(value) {
+	case "no":
+		return UntrackedNo, nil
+	case "normal":
+		return UntrackedNormal, nil
+	case "all":
+		return UntrackedAll, nil
+	}
+	if show, err := strconv.ParseBool(value); err == nil {
+		if show {
+			return UntrackedNormal, nil
+		}
+		return UntrackedNo, nil
+	}
+	return UntrackedDefault, fmt.Errorf("fatal: invalid untracked files mode '%s'", value)
+}
+
+// resolve replaces UntrackedDefault with the configured mode.
+func (mode UntrackedMode) resolve() (UntrackedMode, error) {
+	if mode != UntrackedDefault {
+		return mode, nil
+	}
+	value, isSet, err := ConfigValue("status.showUntrackedFiles")
+	if err != nil || !isSet {
+		return UntrackedNormal, err
+	}
+	return ParseUntrackedMode(value)
+}
+
+// StatusRepo prints the status of the paths selected by the pathspecs
+// (every path when none is given).
+func StatusRepo(pathspecs []string, opts StatusOptions) error {
+	spec, err := ParsePathspec(pathspecs)
 	if err != nil {
-		return fmt.Errorf("error reading .gogitignore: %w", err)
+		return err
 	}
 
-	currentHash, err := GetBranchHash()
+	if opts.Untracked, err = opts.Untracked.resolve(); err != nil {
+		return err
+	}
+	statusInfo, err := collectStatus(spec, opts)
 	if err != nil {
 		return err
 	}
 
-	var treeMap map[string]string
-	if currentHash != "" {
-		lastCommit, err := ReadCommit(currentHash)
-		if err != nil {
-			return err
-		}
+	switch opts.Format {
+	case StatusFormatShort, StatusFormatPorcelain:
+		return PrintShortStatus(statusInfo, opts)
+	case StatusFormatPorcelainV2:
+		PrintPorcelainV2Status(statusInfo, opts)
+	default:
+		PrintStatus(statusInfo)
+	}
 
-		lastTreeHash := lastCommit.Tree
-		treeMap, err = ReadTree(lastTreeHash)
-		if err != nil {
-			return err
-		}
+	return nil
+}
+
+// collectStatus compares HEAD, the index and the working tree for the paths
+// selected by spec, listing the untracked and ignored files as opts asks.
+func collectStatus(spec *Pathspec, opts StatusOptions) (*StatusInfo, error) {
+	head, err := ReadHead()
+	if err != nil {
+		return nil, err
+	}
+	treeMap, err := ReadHeadTree()
+	if err != nil {
+		return nil, err
 	}
 
 	indexMap, err := ReadIndex()
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	worktree, err := scanStatusWorktree(indexMap, spec, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not build the working directory map: %w", err)
+	}
+	workdirMap := worktree.files
+
 	statusInfo := &StatusInfo{
-		Branch:    "main",
-		Staged:    []string{},
-		Unstaged:  []string{},
-		Untracked: []string{},
+		Branch:          head.Branch,
+		Head:            head.Hash,
+		Operation:       InProgressOperation(),
+		UntrackedHidden: opts.Untracked == UntrackedNo,
 	}
 
-	for path, indexHash := range indexMap {
-		commitHash, existsInCommit := treeMap[path]
-		if !existsInCommit {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("new file:   %s", path))
-		} else if indexHash != commitHash {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("modified:   %s", path))
+	if !head.Detached() {
+		statusInfo.Upstream, err = BranchUpstream(head.Branch)
+		if err != nil {
+			return nil, err
+		}
+		if statusInfo.Upstream != nil && statusInfo.Upstream.Hash != "" {
+			statusInfo.Ahead, statusInfo.Behind, err = AheadBehind(head.Hash, statusInfo.Upstream.Hash)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
+
+	paths := make(map[string]bool)
 	for path := range treeMap {
-		if _, existsInIndex := indexMap[path]; !existsInIndex {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("deleted:    %s", path))
+		paths[path] = true
+	}
+	for path := range indexMap {
+		paths[path] = true
+	}
+
+	for path := range paths {
+		if !spec.Match(path) {
+			continue
+		}
+
+		commitEntry, existsInCommit := treeMap[path]
+		indexEntry, existsInIndex := indexMap[path]
+		workdirEntry, existsInWorkdir := workdirMap[path]
+
+		if !existsInIndex {
+			// Staged deletion; the file, if it is still there, is listed
+			// among the untracked ones.
+			statusInfo.Entries = append(statusInfo.Entries, StatusEntry{
+				Path:           path,
+				IndexStatus:    StatusDeleted,
+				WorktreeStatus: StatusUnmodified,
+				HeadMode:       commitEntry.Mode,
+				HeadHash:       commitEntry.Hash,
+			})
+			continue
+		}
+
+		entry := StatusEntry{
+			Path:           path,
+			IndexStatus:    StatusUnmodified,
+			WorktreeStatus: StatusUnmodified,
+		}
+		if existsInCommit {
+			entry.HeadMode, entry.HeadHash = commitEntry.Mode, commitEntry.Hash
+		}
+		if existsInWorkdir {
+			entry.WorktreeMode = workdirEntry.Mode
+		}
+
+		indexTreeEntry := TreeEntry{Mode: indexEntry.Mode, Hash: indexEntry.Hash}
+		switch {
+		case indexEntry.IntentToAdd:
+			// Nothing is staged yet.
+		case !existsInCommit:
+			entry.IndexStatus = StatusAdded
+		default:
+			entry.IndexStatus = changeStatus(commitEntry, indexTreeEntry)
+		}
+		if !indexEntry.IntentToAdd {
+			entry.IndexMode, entry.IndexHash = indexEntry.Mode, indexEntry.Hash
+		}
+
+		switch {
+		case indexEntry.ignoresWorktree():
+			// Skip-worktree or assume-unchanged: the working tree is not looked at.
+		case !existsInWorkdir:
+			entry.WorktreeStatus = StatusDeleted
+		case indexEntry.IntentToAdd:
+			entry.WorktreeStatus = StatusAdded
+		default:
+			entry.WorktreeStatus = changeStatus(indexTreeEntry, workdirEntry)
+		}
+
+		if entry.IndexStatus != StatusUnmodified || entry.WorktreeStatus != StatusUnmodified {
+			statusInfo.Entries = append(statusInfo.Entries, entry)
+		}
+	}
+
+	if statusInfo.Entries, err = pairStatusRenames(statusInfo.Entries, opts.Renames); err != nil {
+		return nil, err
+	}
+
+	for _, path := range worktree.untracked {
+		statusInfo.Entries = append(statusInfo.Entries, StatusEntry{
+			Path:           path,
+			IndexStatus:    StatusUntracked,
+			WorktreeStatus: StatusUntracked,
+		})
+	}
+	for _, path := range worktree.ignored {
+		statusInfo.Entries = append(statusInfo.Entries, StatusEntry{
+			Path:           path,
+			IndexStatus:    StatusIgnored,
+			WorktreeStatus: StatusIgnored,
+		})
+	}
+
+	// Tracked paths first, then untracked ones, then ignored ones, like Git.
+	sort.Slice(statusInfo.Entries, func(i, j int) bool {
+		a, b := statusInfo.Entries[i], statusInfo.Entries[j]
+		if rankA, rankB := statusRank(a), statusRank(b); rankA != rankB {
+			return rankA < rankB
 		}
+		return a.Path < b.Path
+	})
+
+	return statusInfo, nil
+}
+
+// statusRank orders the entries: tracked, untracked, then ignored.
+func statusRank(entry StatusEntry) int {
+	switch entry.IndexStatus {
+	case StatusUntracked:
+		return 1
+	case StatusIgnored:
+		return 2
+	default:
+		return 0
 	}
+}
+
+// statusWorktree is what status finds in the working tree.
+type statusWorktree struct {
+	files     map[string]TreeEntry // the tracked files present, hashed
+	untracked []string             // untracked files, "dir/" for a directory
+	ignored   []string             // ignored files, "dir/" for a directory
+}
+
+// scanStatusWorktree walks the working tree once. Only the tracked files
+// are hashed; the untracked and ignored paths selected by spec are merely
+// listed. In UntrackedNormal mode the walk stops at a directory holding no
+// tracked file, shown as "dir/" when it has an untracked file, so that a new
+// directory takes one line whatever its size. With UntrackedNo and without
+// opts.Ignored only the tracked files are looked at.
+func scanStatusWorktree(indexMap map[string]IndexEntry, spec *Pathspec, opts StatusOptions) (*statusWorktree, error) {
+	filter, err := newWorktreeFilter(indexMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// The daemon, when it runs, already knows the tracked files.
+	monitored, isMonitored, err := monitoredWorkdirMap(filter, indexMap)
+	if err != nil {
+		return nil, err
+	}
+	result := &statusWorktree{files: monitored}
+
+	if opts.Untracked == UntrackedNo && !opts.Ignored {
+		if !isMonitored {
+			files := make([]string, 0, len(indexMap))
+			for filePath := range indexMap {
+				files = append(files, filePath)
+			}
+			if result.files, err = walkWorkdir(filter, files); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	paths := make(chan string, 100)
+	var walkErr error
+	go func() {
+		defer close(paths)
+		walkErr = result.walk(filter, spec, opts, isMonitored, paths)
+	}()
 
-	workdirMap, err := BuildWorkdirMap()
+	scanned, failed, err := scanFiles(paths, false)
 	if err != nil {
-		return fmt.Errorf("could not build the working directory map: %w", err)
+		return nil, err
 	}
+	if walkErr != nil {
+		return nil, fmt.Errorf("error during the directory walk: %w", walkErr)
+	}
+	if failedPaths := sortedFailures(failed); len(failedPaths) > 0 {
+		return nil, fmt.Errorf("could not read the file %s: %w", failedPaths[0], failed[failedPaths[0]])
+	}
+	if !isMonitored {
+		result.files = scanned
+	}
+	return result, nil
+}
+
+// walk lists the untracked and ignored paths and sends the tracked files
+// to hash, unless the daemon already gave them.
+func (w *statusWorktree) walk(filter *worktreeFilter, spec *Pathspec, opts StatusOptions, monitored bool, paths chan<- string) error {
+	all := opts.Untracked == UntrackedAll
+	collapsed := "" // the untracked directory listed as a whole, if any
 
-	filteredWorkdirMap := make(map[string]string)
-	for path, hash := range workdirMap {
-		ignored, err := isIgnored(path, ignorePatterns)
+	return filepath.WalkDir(".", func(osPath string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("error checking ignore patterns for %s: %w", path, err)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
 		}
-		if !ignored {
-			filteredWorkdirMap[path] = hash
+		filePath := filepath.ToSlash(osPath)
+		if filePath == "." {
+			return nil
+		}
+		if d.Name() == ROOT || (d.IsDir() && d.Name() == ".git") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if collapsed != "" && !strings.HasPrefix(filePath, collapsed) {
+			collapsed = ""
 		}
-	}
 
-	for path, workdirHash := range filteredWorkdirMap {
-		indexHash, existsInIndex := indexMap[path]
-		if !existsInIndex {
-			// Case C: Untracked
-			statusInfo.Untracked = append(statusInfo.Untracked, path)
-		} else if workdirHash != indexHash {
-			// Case D: Modified Unstaged
-			statusInfo.Unstaged = append(statusInfo.Unstaged, fmt.Sprintf("modified:   %s", path))
+		if filter.tracked[filePath] {
+			if !d.IsDir() && !monitored {
+				paths <- filePath
+			}
+			return nil
+		}
+
+		ignored, err := filter.ignore.IsIgnored(filePath, d.IsDir())
+		if err != nil {
+			return err
+		}
+		if ignored {
+			switch {
+			case !opts.Ignored:
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+			case !d.IsDir():
+				if spec.Match(filePath) {
+					w.ignored = append(w.ignored, filePath)
+				}
+			case !all && spec.Match(filePath):
+				w.ignored = append(w.ignored, filePath+"/")
+				return filepath.SkipDir
+			}
+			// Within an ignored directory every file is ignored as well.
+			return nil
+		}
+
+		if opts.Untracked == UntrackedNo || collapsed != "" {
+			return nil
 		}
+		if !d.IsDir() {
+			if spec.Match(filePath) {
+				w.untracked = append(w.untracked, filePath)
+			}
+			return nil
+		}
+		if all {
+			return nil
+		}
+
+		// An untracked directory: there is nothing to hash below it, and it
+		// is walked further only for the ignored paths it may hold.
+		hasUntracked, err := containsUntracked(filter, spec, osPath)
+		if err != nil {
+			return err
+		}
+		if hasUntracked {
+			w.untracked = append(w.untracked, filePath+"/")
+		}
+		if !opts.Ignored {
+			return filepath.SkipDir
+		}
+		collapsed = filePath + "/"
+		return nil
+	})
+}
+
+// containsUntracked reports whether the untracked directory dir holds a
+// file that is neither ignored nor left out by spec, stopping at the first
+// one.
+func containsUntracked(filter *worktreeFilter, spec *Pathspec, dir string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(osPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if osPath == dir {
+			return nil
+		}
+		filePath := filepath.ToSlash(osPath)
+		skip, err := filter.skip(filePath, d.IsDir())
+		if err != nil {
+			return err
+		}
+		switch {
+		case skip && d.IsDir():
+			return filepath.SkipDir
+		case skip || d.IsDir() || !spec.Match(filePath):
+			return nil
+		}
+		found = true
+		return filepath.SkipAll
+	})
+	return found, err
+}
+
+// changeStatus returns the status letter for a path that exists on both
+// sides: unmodified, modified, or type changed between file and symlink.
+func changeStatus(oldEntry, newEntry TreeEntry) byte {
+	switch {
+	case oldEntry == newEntry:
+		return StatusUnmodified
+	case (oldEntry.Mode == ModeSymlink) != (newEntry.Mode == ModeSymlink):
+		return StatusTypeChanged
+	default:
+		return StatusModified
 	}
-	// Iterate over the index to find unstaged deletions
-	for path := range indexMap {
-		if _, existsInWorkdir := workdirMap[path]; !existsInWorkdir {
-			statusInfo.Unstaged = append(statusInfo.Unstaged, fmt.Sprintf("deleted:    %s", path))
+}
+
+// pairStatusRenames turns the staged deletions and additions that are
+// renames (or copies) of each other into single entries. As in Git, only the
+// changes between HEAD and the index are paired: a file moved in the working
+// tree shows up as deleted and untracked until both sides are staged.
+func pairStatusRenames(entries []StatusEntry, opts RenameOptions) ([]StatusEntry, error) {
+	deleted := make(map[string]TreeEntry)
+	added := make(map[string]TreeEntry)
+	modified := make(map[string]TreeEntry)
+	positions := make(map[string]int)
+	for i, entry := range entries {
+		if entry.IndexStatus == StatusUntracked || entry.IndexStatus == StatusIgnored {
+			continue
 		}
+		positions[entry.Path] = i
+		switch entry.IndexStatus {
+		case StatusDeleted:
+			deleted[entry.Path] = TreeEntry{Mode: entry.HeadMode, Hash: entry.HeadHash}
+		case StatusAdded:
+			added[entry.Path] = TreeEntry{Mode: entry.IndexMode, Hash: entry.IndexHash}
+		case StatusModified, StatusTypeChanged:
+			modified[entry.Path] = TreeEntry{Mode: entry.HeadMode, Hash: entry.HeadHash}
+		}
+	}
+
+	loadOld := func(path string) ([]byte, error) {
+		return readObjectContent(entries[positions[path]].HeadHash)
+	}
+	loadNew := func(path string) ([]byte, error) {
+		return readObjectContent(entries[positions[path]].IndexHash)
+	}
+	pairs, err := detectRenames(deleted, added, modified, opts, loadOld, loadNew)
+	if err != nil || len(pairs) == 0 {
+		return entries, err
 	}
 
-	PrintStatus(statusInfo)
+	renamed := make(map[string]bool)
+	for _, pair := range pairs {
+		source := entries[positions[pair.from]]
+		entry := &entries[positions[pair.to]]
+		entry.OrigPath, entry.Score = pair.from, pair.score
+		entry.HeadMode, entry.HeadHash = source.HeadMode, source.HeadHash
+		entry.IndexStatus = StatusRenamed
+		if pair.copy {
+			entry.IndexStatus = StatusCopied
+		} else {
+			renamed[pair.from] = true
+		}
+	}
 
-	return nil
+	// The deletions are now part of the renames.
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.IndexStatus != StatusDeleted || !renamed[entry.Path] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
 }
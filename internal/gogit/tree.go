@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
-func ReadTree(hash string) (map[string]string, error) {
-	treeMap := make(map[string]string)
+func ReadTree(hash string) (map[string]TreeEntry, error) {
+	treeMap := make(map[string]TreeEntry)
 	treePath := fmt.Sprintf("%s/%s/%s", ObjectsPath, hash[:2], hash[2:])
 
 	treeFile, err := os.Open(treePath)
@@ -38,9 +39,10 @@ func ReadTree(hash string) (map[string]string, error) {
 			continue
 		}
 
+		mode := header[0]
 		hash := header[2]
 		path := parts[1]
-		treeMap[path] = hash
+		treeMap[path] = TreeEntry{Mode: mode, Hash: hash}
 	}
 
 	// 8. Check for errors during scanning
@@ -50,3 +52,40 @@ func ReadTree(hash string) (map[string]string, error) {
 
 	return treeMap, nil
 }
+
+// ReadHeadTree returns the tree of the commit the current branch points to,
+// or an empty map when the branch has no commits yet.
+func ReadHeadTree() (map[string]TreeEntry, error) {
+	currentHash, err := GetBranchHash()
+	if err != nil {
+		return nil, err
+	}
+	if currentHash == "" {
+		return make(map[string]TreeEntry), nil
+	}
+
+	lastCommit, err := ReadCommit(currentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadTree(lastCommit.Tree)
+}
+
+// ChangedPaths returns, sorted, the paths that were added, removed or
+// modified between two trees.
+func ChangedPaths(oldTree, newTree map[string]TreeEntry) []string {
+	var paths []string
+	for path, oldEntry := range oldTree {
+		if newEntry, exists := newTree[path]; !exists || newEntry != oldEntry {
+			paths = append(paths, path)
+		}
+	}
+	for path := range newTree {
+		if _, exists := oldTree[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -12,9 +12,87 @@ type Commit struct {
 	Message string
 }
 
+// TreeEntry is a single file recorded in a tree object (or found in the
+// working directory): its mode and the hash of its blob.
+type TreeEntry struct {
+	Mode string
+	Hash string
+}
+
+// IndexEntry is a single file staged in the index.
+type IndexEntry struct {
+	Mode string
+	Hash string
+	// IntentToAdd marks a path recorded with `add -N`: it is tracked, but its
+	// content has not been staged yet (Hash is the empty blob).
+	IntentToAdd bool
+	// SkipWorktree marks a path whose working tree file is not looked at:
+	// it is left out by a sparse checkout, or set with update-index.
+	SkipWorktree bool
+	// AssumeUnchanged tells status and add to trust the index instead of the
+	// working tree for this path (`update-index --assume-unchanged`).
+	AssumeUnchanged bool
+}
+
+// ignoresWorktree reports whether the working tree file of the entry must
+// not be compared with the index, because of its skip-worktree or
+// assume-unchanged flag.
+func (e IndexEntry) ignoresWorktree() bool {
+	return e.SkipWorktree || e.AssumeUnchanged
+}
+
+// StatusInfo is the result of a status computation: where HEAD is, how the
+// branch compares with its upstream, and one entry per changed path.
 type StatusInfo struct {
-	Branch    string
-	Staged    []string
-	Unstaged  []string
-	Untracked []string
+	Branch string // current branch, empty when HEAD is detached
+	Head   string // commit hash of HEAD, empty on a branch without commits
+
+	// Upstream is the tracked branch, nil when none is configured. Ahead and
+	// Behind count the commits on each side that the other one lacks.
+	Upstream      *Upstream
+	Ahead, Behind int
+
+	// Operation is the merge, rebase, etc. in progress, if any.
+	Operation *Operation
+
+	// Entries are sorted by path, untracked files then ignored files last.
+	Entries []StatusEntry
+	// UntrackedHidden is set when untracked files were not looked for.
+	UntrackedHidden bool
 }
+
+// StatusEntry describes how one path differs between HEAD, the index and the
+// working tree. The two states use the letters of `git status --short`:
+//
+//	' ' unmodified   'M' modified   'T' type changed (file <-> symlink)
+//	'A' added        'D' deleted    'R' renamed       'C' copied
+//	'?' untracked    '!' ignored
+//
+// An untracked file has '?' in both states and an ignored one '!'; a
+// directory shown as a whole has a trailing "/". An intent-to-add file has
+// ' ' in the index and 'A' in the working tree.
+type StatusEntry struct {
+	Path     string
+	OrigPath string // source path of a rename or copy, empty otherwise
+	Score    int    // similarity of a rename or copy, in percent
+
+	IndexStatus    byte // index compared with HEAD
+	WorktreeStatus byte // working tree compared with the index
+
+	// Modes and hashes of the path on each side, empty when absent.
+	HeadMode, IndexMode, WorktreeMode string
+	HeadHash, IndexHash               string
+}
+
+// Status letters used in StatusEntry.
+const (
+	StatusUnmodified  = ' '
+	StatusModified    = 'M'
+	StatusTypeChanged = 'T'
+	StatusAdded       = 'A'
+	StatusDeleted     = 'D'
+	StatusRenamed     = 'R'
+	StatusCopied      = 'C'
+	StatusUntracked   = '?'
+	StatusIgnored     = '!'
+)
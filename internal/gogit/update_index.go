@@ -0,0 +1,66 @@
+package gogit
+
+import (
+	"fmt"
+)
+
+// UpdateIndexOptions selects the index flags UpdateIndex sets or clears.
+type UpdateIndexOptions struct {
+	AssumeUnchanged   bool
+	NoAssumeUnchanged bool
+	SkipWorktree      bool
+	NoSkipWorktree    bool
+}
+
+// UpdateIndex sets or clears the assume-unchanged and skip-worktree flags of
+// tracked paths (equivalent to `git update-index`). Paths are taken literally,
+// relative to the current directory.
+func UpdateIndex(paths []string, opts UpdateIndexOptions) error {
+	if opts.AssumeUnchanged && opts.NoAssumeUnchanged {
+		return fmt.Errorf("fatal: --assume-unchanged and --no-assume-unchanged are mutually exclusive")
+	}
+	if opts.SkipWorktree && opts.NoSkipWorktree {
+		return fmt.Errorf("fatal: --skip-worktree and --no-skip-worktree are mutually exclusive")
+	}
+
+	indexLock, err := LockIndex()
+	if err != nil {
+		return err
+	}
+	defer indexLock.Rollback()
+
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	for _, arg := range paths {
+		filePath, inside := rootPath(arg)
+		if !inside {
+			return fmt.Errorf("fatal: %s: '%s' is outside repository", arg, arg)
+		}
+		entry, exists := indexEntries[filePath]
+		if !exists {
+			return fmt.Errorf("fatal: Unable to mark file %s", arg)
+		}
+
+		switch {
+		case opts.AssumeUnchanged:
+			entry.AssumeUnchanged = true
+		case opts.NoAssumeUnchanged:
+			entry.AssumeUnchanged = false
+		}
+		switch {
+		case opts.SkipWorktree:
+			entry.SkipWorktree = true
+		case opts.NoSkipWorktree:
+			entry.SkipWorktree = false
+		}
+		indexEntries[filePath] = entry
+	}
+
+	if err := WriteIndex(indexLock, indexEntries); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Upstream is the branch that a local branch tracks, configured with
+// branch.<name>.remote and branch.<name>.merge. The remote "." means a local
+// branch; any other remote is looked up under refs/remotes.
+type Upstream struct {
+	// Name is the short name shown to the user, such as "origin/main".
+	Name string
+	// Hash is the commit of the upstream, empty when its ref is gone.
+	Hash string
+}
+
+// BranchUpstream returns the upstream of branch, or nil when none is
+// configured.
+func BranchUpstream(branch string) (*Upstream, error) {
+	remote, hasRemote, err := ConfigValue(fmt.Sprintf("branch.%s.remote", branch))
+	if err != nil {
+		return nil, err
+	}
+	merge, hasMerge, err := ConfigValue(fmt.Sprintf("branch.%s.merge", branch))
+	if err != nil {
+		return nil, err
+	}
+	if !hasRemote || !hasMerge {
+		return nil, nil
+	}
+
+	mergeBranch := strings.TrimPrefix(merge, "refs/heads/")
+	upstream := &Upstream{Name: mergeBranch}
+	refPath := filepath.Join(RefHeadsPath, mergeBranch)
+	if remote != "." {
+		upstream.Name = remote + "/" + mergeBranch
+		refPath = filepath.Join(RefRemotesPath, remote, mergeBranch)
+	}
+
+	upstream.Hash, err = readRef(refPath)
+	if err != nil {
+		return nil, err
+	}
+	return upstream, nil
+}
+
+// SetUpstream makes branch (the current branch when empty) track upstream,
+// which is either a remote-tracking branch such as "origin/main" or a local
+// branch.
+func SetUpstream(branch, upstream string) error {
+	if branch == "" {
+		head, err := ReadHead()
+		if err != nil {
+			return err
+		}
+		if head.Detached() {
+			return fmt.Errorf("fatal: could not set upstream of HEAD to %s when it does not point to any branch", upstream)
+		}
+		branch = head.Branch
+	}
+	if exists, err := CheckIfBranchExists(branch); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("fatal: branch '%s' does not exist", branch)
+	}
+
+	remote, mergeBranch := ".", upstream
+	if _, err := os.Stat(filepath.Join(RefRemotesPath, upstream)); err == nil && strings.Contains(upstream, "/") {
+		remote, mergeBranch, _ = strings.Cut(upstream, "/")
+	} else if exists, err := CheckIfBranchExists(upstream); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("fatal: the requested upstream branch '%s' does not exist", upstream)
+	}
+	if remote == "." && mergeBranch == branch {
+		fmt.Fprintf(os.Stderr, "warning: not setting branch '%s' as its own upstream\n", branch)
+		return nil
+	}
+
+	if err := SetRepoConfig(fmt.Sprintf("branch.%s.remote", branch), remote); err != nil {
+		return err
+	}
+	if err := SetRepoConfig(fmt.Sprintf("branch.%s.merge", branch), "refs/heads/"+mergeBranch); err != nil {
+		return err
+	}
+
+	fmt.Printf("branch '%s' set up to track '%s'.\n", branch, upstream)
+	return nil
+}
+
+// UnsetUpstream removes the upstream configuration of branch (the current
+// branch when empty).
+func UnsetUpstream(branch string) error {
+	if branch == "" {
+		head, err := ReadHead()
+		if err != nil {
+			return err
+		}
+		if head.Detached() {
+			return fmt.Errorf("fatal: HEAD does not point to a branch")
+		}
+		branch = head.Branch
+	}
+
+	upstream, err := BranchUpstream(branch)
+	if err != nil {
+		return err
+	}
+	if upstream == nil {
+		return fmt.Errorf("fatal: branch '%s' has no upstream information", branch)
+	}
+
+	if err := UnsetRepoConfig(fmt.Sprintf("branch.%s.remote", branch)); err != nil {
+		return err
+	}
+	return UnsetRepoConfig(fmt.Sprintf("branch.%s.merge", branch))
+}
+
+// AheadBehind counts the commits reachable from local but not from upstream
+// (ahead) and the other way around (behind).
+func AheadBehind(local, upstream string) (ahead, behind int, err error) {
+	localCommits, err := commitAncestors(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	upstreamCommits, err := commitAncestors(upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for hash := range localCommits {
+		if !upstreamCommits[hash] {
+			ahead++
+		}
+	}
+	for hash := range upstreamCommits {
+		if !localCommits[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// commitAncestors returns hash and every commit reachable from it.
+func commitAncestors(hash string) (map[string]bool, error) {
+	ancestors := make(map[string]bool)
+	for hash != "" && !ancestors[hash] {
+		ancestors[hash] = true
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, fmt.Errorf("error reading commit %s: %w", hash, err)
+		}
+		hash = commit.Parent
+	}
+	return ancestors, nil
+}
@@ -3,8 +3,6 @@ package gogit
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io/fs"
 	"log"
@@ -51,9 +49,11 @@ func GetHeadRef() (map[string]string, error) {
 	return headRef, nil
 }
 
-// readIndex reads the index file into a map.
-func ReadIndex() (map[string]string, error) {
-	indexEntries := make(map[string]string)
+// ReadIndex reads the index file into a map of path -> entry.
+// Each line has the form "<mode> <hash>[ <flag>...]\t<path>". Lines written by
+// older versions ("<hash> <path>") are still accepted and treated as regular files.
+func ReadIndex() (map[string]IndexEntry, error) {
+	indexEntries := make(map[string]IndexEntry)
 	indexFile, err := os.Open(IndexPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -67,10 +67,34 @@ func ReadIndex() (map[string]string, error) {
 	scanner := bufio.NewScanner(indexFile)
 	for scanner.Scan() {
 		line := scanner.Text()
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) == 2 {
-			indexEntries[parts[1]] = parts[0] // map[filepath] = hash
+
+		meta, path, found := strings.Cut(line, "\t")
+		if !found {
+			// Legacy format: "<hash> <path>".
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) == 2 {
+				indexEntries[parts[1]] = IndexEntry{Mode: ModeRegular, Hash: parts[0]}
+			}
+			continue
+		}
+
+		fields := strings.Fields(meta)
+		if len(fields) < 2 {
+			log.Printf("Skipping index line with incorrect format: %s", line)
+			continue
+		}
+		entry := IndexEntry{Mode: fields[0], Hash: fields[1]}
+		for _, flag := range fields[2:] {
+			switch flag {
+			case indexFlagIntentToAdd:
+				entry.IntentToAdd = true
+			case indexFlagSkipWorktree:
+				entry.SkipWorktree = true
+			case indexFlagAssumeUnchanged:
+				entry.AssumeUnchanged = true
+			}
 		}
+		indexEntries[path] = entry
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error scanning index file: %w", err)
@@ -78,8 +102,10 @@ func ReadIndex() (map[string]string, error) {
 	return indexEntries, nil
 }
 
-// writeIndex writes the map of entries to the index file.
-func WriteIndex(indexEntries map[string]string) error {
+// WriteIndex writes the map of entries to the index file and releases the
+// index lock, which the caller must have taken with LockIndex before reading
+// the entries it modified.
+func WriteIndex(indexLock *Lockfile, indexEntries map[string]IndexEntry) error {
 	var lines []string
 	// For deterministic output, sort the file paths before writing.
 	var paths []string
@@ -89,7 +115,18 @@ func WriteIndex(indexEntries map[string]string) error {
 	sort.Strings(paths)
 
 	for _, path := range paths {
-		lines = append(lines, fmt.Sprintf("%s %s", indexEntries[path], path))
+		entry := indexEntries[path]
+		meta := entry.Mode + " " + entry.Hash
+		if entry.IntentToAdd {
+			meta += " " + indexFlagIntentToAdd
+		}
+		if entry.SkipWorktree {
+			meta += " " + indexFlagSkipWorktree
+		}
+		if entry.AssumeUnchanged {
+			meta += " " + indexFlagAssumeUnchanged
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s", meta, path))
 	}
 
 	output := strings.Join(lines, "\n")
@@ -97,42 +134,54 @@ func WriteIndex(indexEntries map[string]string) error {
 		output += "\n" // Add a final newline
 	}
 
-	if err := os.WriteFile(IndexPath, []byte(output), 0644); err != nil {
+	if err := indexLock.Commit([]byte(output)); err != nil {
 		return fmt.Errorf("error writing to index file %s: %w", IndexPath, err)
 	}
 	return nil
 }
 
-func GetBranchHash() (string, error) {
-	headFile, err := os.Open(HeadPath)
-	if err != nil {
-		return "", err
+// IndexToTree converts index entries into the entries of a tree object.
+// Intent-to-add entries have no staged content yet and are left out.
+func IndexToTree(indexEntries map[string]IndexEntry) map[string]TreeEntry {
+	treeEntries := make(map[string]TreeEntry, len(indexEntries))
+	for path, entry := range indexEntries {
+		if entry.IntentToAdd {
+			continue
+		}
+		treeEntries[path] = TreeEntry{Mode: entry.Mode, Hash: entry.Hash}
 	}
-	defer headFile.Close()
+	return treeEntries
+}
 
-	var headRef string
-	headScanner := bufio.NewScanner(headFile)
-	for headScanner.Scan() {
-		line := headScanner.Text()
+// TreeToIndex converts the entries of a tree object into index entries.
+func TreeToIndex(treeEntries map[string]TreeEntry) map[string]IndexEntry {
+	indexEntries := make(map[string]IndexEntry, len(treeEntries))
+	for path, entry := range treeEntries {
+		indexEntries[path] = IndexEntry{Mode: entry.Mode, Hash: entry.Hash}
+	}
+	return indexEntries
+}
 
-		words := strings.Fields(line)
-		headRef = words[1]
+// keepIndexFlags copies the skip-worktree and assume-unchanged flags set by
+// the user from oldEntries to the entries of newEntries for the same paths.
+func keepIndexFlags(newEntries, oldEntries map[string]IndexEntry) {
+	for path, entry := range newEntries {
+		if old, exists := oldEntries[path]; exists {
+			entry.SkipWorktree = entry.SkipWorktree || old.SkipWorktree
+			entry.AssumeUnchanged = old.AssumeUnchanged
+			newEntries[path] = entry
+		}
 	}
+}
 
-	branchRefPath := fmt.Sprintf("%s/%s", RepoPath, headRef)
-	branchHashFile, err := os.Open(branchRefPath)
+// GetBranchHash returns the commit HEAD points at, or an empty string on a
+// branch without commits.
+func GetBranchHash() (string, error) {
+	head, err := ReadHead()
 	if err != nil {
 		return "", err
 	}
-	defer branchHashFile.Close()
-
-	var currentHash string
-	brandHashScanner := bufio.NewScanner(branchHashFile)
-	for brandHashScanner.Scan() {
-		currentHash = brandHashScanner.Text()
-	}
-
-	return currentHash, nil
+	return head.Hash, nil
 }
 
 func GetTargetBranchHash(branchName string) (string, error) {
@@ -152,119 +201,84 @@ func GetTargetBranchHash(branchName string) (string, error) {
 	return targetHash, nil
 }
 
-// BuildWorkdirMap walks the repoRoot and returns a map of relative path -> sha1hex.
-func BuildWorkdirMap() (map[string]string, error) {
-	repoRoot, err := os.Getwd()
+// BuildWorkdirMap walks the working tree and returns a map of relative path -> entry.
+// The files are hashed in parallel by the shared scanner. When core.fsmonitor
+// is enabled and the daemon is running, only the paths it reports as changed
+// since the previous run are read again.
+func BuildWorkdirMap() (map[string]TreeEntry, error) {
+	// 1. Load the ignore rules; tracked files are never ignored.
+	indexMap, err := ReadIndex()
 	if err != nil {
-		log.Fatalf("could not get the current directory: %v", err)
+		return nil, err
 	}
-	workdirMap := make(map[string]string)
-
-	// 1. Load the .gogitignore rules.
-	ignorePatterns, err := parseGitignore(repoRoot)
+	filter, err := newWorktreeFilter(indexMap)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. Start the recursive walk.
-	walkErr := filepath.WalkDir(repoRoot, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+	workdirMap, monitored, err := monitoredWorkdirMap(filter, indexMap)
+	if err != nil || monitored {
+		return workdirMap, err
+	}
+	return walkWorkdir(filter, []string{"."})
+}
+
+// walkWorkdir hashes the files found below roots (paths relative to the
+// repository root) that filter keeps.
+func walkWorkdir(filter *worktreeFilter, roots []string) (map[string]TreeEntry, error) {
+	// 2. Start the recursive walk, feeding the scanner.
+	pathsChan := make(chan string, 100)
+	var walkErr error
+	go func() {
+		defer close(pathsChan)
+		for _, root := range roots {
+			if walkErr = walkWorktreePaths(filter, root, pathsChan); walkErr != nil {
+				return
+			}
 		}
+	}()
+
+	// 3. Hash the files in parallel while the walk goes on.
+	workdirMap, failed, err := scanFiles(pathsChan, false)
+	if err != nil {
+		return nil, err
+	}
+	if walkErr != nil {
+		return nil, fmt.Errorf("error during the directory walk: %w", walkErr)
+	}
+	if paths := sortedFailures(failed); len(paths) > 0 {
+		return nil, fmt.Errorf("could not read the file %s: %w", paths[0], failed[paths[0]])
+	}
+
+	return workdirMap, nil
+}
 
-		// Get the relative path to the repository root.
-		relativePath, err := filepath.Rel(repoRoot, path)
+// walkWorktreePaths sends the files below root that filter keeps to paths,
+// relative to the repository root and with forward slashes.
+func walkWorktreePaths(filter *worktreeFilter, root string, paths chan<- string) error {
+	return filepath.WalkDir(filepath.FromSlash(root), func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
+			// A file removed during the walk is simply not there.
+			if os.IsNotExist(err) {
+				return nil
+			}
 			return err
 		}
+
 		// Normalize to forward slashes for consistent comparison.
-		relativePath = filepath.ToSlash(relativePath)
+		relativePath := filepath.ToSlash(filepath.Clean(path))
 
 		// Skip the root (".").
 		if relativePath == "." {
 			return nil
 		}
 
-		// Ignore the .gogitignore file itself.
-		if !d.IsDir() && filepath.Base(relativePath) == ".gogitignore" {
-			return nil
-		}
-
-		// Strict Filter: always ignore the .gogit directory.
-		if d.IsDir() && (relativePath == ".gogit" || strings.HasPrefix(relativePath, ".gogit/")) {
-			return filepath.SkipDir
-		}
-
-		// Evaluate ignore rules (they are applied in order; '!' negations undo previous ignores).
-		isIgnored := false
-		name := d.Name() // name of the file/dir
-		isDir := d.IsDir()
-
-		for _, rawPattern := range ignorePatterns {
-			if rawPattern == "" {
-				continue
-			}
-			pattern := filepath.ToSlash(strings.TrimSpace(rawPattern))
-
-			negated := false
-			if strings.HasPrefix(pattern, "!") {
-				negated = true
-				pattern = strings.TrimPrefix(pattern, "!")
-				pattern = strings.TrimSpace(pattern)
-				if pattern == "" {
-					// invalid "!" pattern -> ignore
-					continue
-				}
-			}
-
-			// If the pattern ends in "/" it points to directories.
-			patternDirOnly := strings.HasSuffix(pattern, "/")
-			if patternDirOnly {
-				pattern = strings.TrimSuffix(pattern, "/")
-			}
-
-			matched := false
-
-			// If the pattern contains a '/' we compare it against the full relative path.
-			if strings.Contains(pattern, "/") {
-				// if pattern starts with "/" we treat it as relative to the root: remove prefix if it exists
-				if strings.HasPrefix(pattern, "/") {
-					pattern = strings.TrimPrefix(pattern, "/")
-				}
-				// Match using filepath.Match against relativePath
-				if ok, matchErr := filepath.Match(pattern, relativePath); matchErr == nil && ok {
-					matched = true
-				} else if matchErr != nil {
-					// invalid pattern — we ignore it
-					continue
-				}
-			} else {
-				// does not contain '/', compare against the name of the file/dir
-				if ok, matchErr := filepath.Match(pattern, name); matchErr == nil && ok {
-					matched = true
-				} else if matchErr != nil {
-					continue
-				}
-			}
-
-			// If the pattern is exclusive to directories, and this is not a dir -> no match
-			if matched && patternDirOnly && !isDir {
-				matched = false
-			}
-
-			if matched {
-				if negated {
-					// A negation undoes the ignore state.
-					isIgnored = false
-				} else {
-					isIgnored = true
-				}
-				// We don't break; git processes all lines (last relevant match).
-			}
+		// Leave out the .gogit directory and the ignored paths.
+		skip, err := filter.skip(relativePath, d.IsDir())
+		if err != nil {
+			return err
 		}
-
-		// If it's ignored -> if it's a dir, avoid entering; if it's a file, skip it.
-		if isIgnored {
+		if skip {
 			if d.IsDir() {
 				return filepath.SkipDir
 			}
@@ -276,60 +290,10 @@ func BuildWorkdirMap() (map[string]string, error) {
 			return nil
 		}
 
-		// 3. Process and hash each valid file.
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("could not read the file %s: %w", path, err)
-		}
-
-		// Create a "blob <size>\0" header.
-		header := fmt.Sprintf("blob %d\x00", len(content))
-
-		// Concatenate and hash.
-		hasher := sha1.New()
-		_, _ = hasher.Write([]byte(header))
-		_, _ = hasher.Write(content)
-		hashBytes := hasher.Sum(nil)
-
-		// Convert to hexadecimal and store.
-		hashHex := hex.EncodeToString(hashBytes)
-		// Save with the relative path (without "./").
-		workdirMap[relativePath] = hashHex
-
+		// Hand the file to the scanner (symlinks are hashed by their target).
+		paths <- relativePath
 		return nil
 	})
-
-	if walkErr != nil {
-		return nil, fmt.Errorf("error during the directory walk: %w", walkErr)
-	}
-
-	return workdirMap, nil
-}
-
-// parseGitignore reads .gogitignore and returns the lines in order (including negations).
-func parseGitignore(repoRoot string) ([]string, error) {
-	ignoreFilePath := filepath.Join(repoRoot, ".gogitignore")
-
-	content, err := os.ReadFile(ignoreFilePath)
-	if err != nil {
-		// If there is no .gogitignore, it is not an error, there is simply nothing to ignore.
-		if os.IsNotExist(err) {
-			return []string{}, nil
-		}
-		return nil, fmt.Errorf("error reading .gogitignore: %w", err)
-	}
-
-	var patterns []string
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		// Ignore empty lines and comments.
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
-			continue
-		}
-		patterns = append(patterns, trimmedLine)
-	}
-	return patterns, nil
 }
 
 func CheckIfBranchExists(branchName string) (bool, error) {
@@ -345,33 +309,51 @@ func CheckIfBranchExists(branchName string) (bool, error) {
 	return false, fmt.Errorf("error checking if branch exists: %w", err)
 }
 
-func ApplyDiffCheckout(currentTreeMap map[string]string, targetTreeMap map[string]string) error {
+// ApplyDiffCheckout turns the working tree of currentTreeMap into the one of
+// targetTreeMap. With a sparse checkout only the paths it includes are
+// written; the others stay out of the working tree.
+func ApplyDiffCheckout(currentTreeMap map[string]TreeEntry, targetTreeMap map[string]TreeEntry, sparse *SparseCheckout) error {
 	// Files to delete: in current but not in target
 	for path := range currentTreeMap {
 		if _, existsInTarget := targetTreeMap[path]; !existsInTarget {
-			if err := os.Remove(path); err != nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 				return fmt.Errorf("error deleting file %s: %w", path, err)
 			}
 		}
 	}
 
-	// Files to add or modify: in target (new or different hash)
-	for path, targetHash := range targetTreeMap {
-		currentHash, existsInCurrent := currentTreeMap[path]
-		if !existsInCurrent || currentHash != targetHash {
-			// Read blob object
-			blobContent, err := readObjectContent(targetHash)
-			if err != nil {
-				return fmt.Errorf("error reading blob object %s: %w", targetHash, err)
+	// Files to add or modify: in target (new, different hash or different
+	// mode). The attribute files go first, so that the other files are
+	// converted according to the attributes of the target.
+	var toWrite []string
+	for path, targetEntry := range targetTreeMap {
+		currentEntry, existsInCurrent := currentTreeMap[path]
+		if !sparse.Includes(path) {
+			// Outside the sparse checkout: a stale copy is removed, nothing is written.
+			if existsInCurrent && currentEntry != targetEntry {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("error deleting file %s: %w", path, err)
+				}
+				removeEmptyParents(path)
 			}
+			continue
+		}
+		if !existsInCurrent || currentEntry != targetEntry {
+			toWrite = append(toWrite, path)
+		}
+	}
+	sort.SliceStable(toWrite, func(i, j int) bool {
+		return filepath.Base(toWrite[i]) == attributesFileName && filepath.Base(toWrite[j]) != attributesFileName
+	})
 
-			// Write to working directory
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-				return fmt.Errorf("error creating directories for %s: %w", path, err)
-			}
-			if err := os.WriteFile(path, blobContent, 0644); err != nil {
-				return fmt.Errorf("error writing file %s: %w", path, err)
-			}
+	reloaded := false
+	for _, path := range toWrite {
+		if !reloaded && filepath.Base(path) != attributesFileName {
+			reloadAttributes()
+			reloaded = true
+		}
+		if err := writeWorktreeFile(path, targetTreeMap[path]); err != nil {
+			return err
 		}
 	}
 
@@ -379,7 +361,7 @@ func ApplyDiffCheckout(currentTreeMap map[string]string, targetTreeMap map[strin
 }
 
 func readObjectContent(objectHash string) ([]byte, error) {
-	objectPath := filepath.Join(ObjectsPath, objectHash)
+	objectPath := filepath.Join(ObjectsPath, objectHash[:2], objectHash[2:])
 	content, err := os.ReadFile(objectPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading object %s: %w", objectHash, err)
@@ -395,16 +377,34 @@ func readObjectContent(objectHash string) ([]byte, error) {
 }
 
 func UpdateHeadRef(branchName string) error {
-	headFile, err := os.OpenFile(HeadPath, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening HEAD for writing: %w", err)
+	content := fmt.Sprintf("ref: refs/heads/%s\n", branchName)
+	if err := writeLockedFile(HeadPath, []byte(content)); err != nil {
+		return fmt.Errorf("error writing to HEAD file: %w", err)
 	}
-	defer headFile.Close()
 
-	_, err = headFile.WriteString(fmt.Sprintf("ref: refs/heads/%s\n", branchName))
+	return nil
+}
+
+// UpdateBranchRef points the branch referenced by HEAD (or HEAD itself when
+// detached) at commitHash.
+func UpdateBranchRef(commitHash string) error {
+	branchRefPath, err := headTargetPath()
 	if err != nil {
-		return fmt.Errorf("error writing to HEAD file: %w", err)
+		return err
+	}
+
+	if err := writeLockedFile(branchRefPath, []byte(commitHash+"\n")); err != nil {
+		return fmt.Errorf("error updating branch reference file: %w", err)
 	}
 
 	return nil
 }
+
+// ShortHash abbreviates a hash for display, like Git's default of 7 characters.
+func ShortHash(hash string) string {
+	const shortLen = 7
+	if len(hash) <= shortLen {
+		return hash
+	}
+	return hash[:shortLen]
+}
@@ -0,0 +1,227 @@
+package gogit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wildmatch matches text against a shell wildcard pattern the way Git does.
+// It understands "*", "?", bracket expressions ("[a-z]", "[!0-9]",
+// "[[:alpha:]]") and backslash escapes.
+//
+// When pathname is true, wildcards never match a "/" and "**" forms a
+// special component: "**/" matches zero or more leading directories, "/**"
+// matches everything inside and "/**/" matches zero or more directories.
+// When pathname is false, "*" happily matches across "/".
+//
+// The positions a star tries are memoized, so that patterns with many stars
+// stay quadratic instead of backtracking exponentially on long paths.
+func wildmatch(pattern, text string, pathname bool) bool {
+	var match func(pi, ti int) bool
+
+	// memo holds the result of match(pi, ti) at pi*(len(text)+1)+ti:
+	// 0 when not computed yet, 1 for a match and 2 for no match. It is only
+	// allocated once a star has to try several positions.
+	var memo []byte
+	try := func(pi, ti int) bool {
+		if memo == nil {
+			memo = make([]byte, (len(pattern)+1)*(len(text)+1))
+		}
+		key := pi*(len(text)+1) + ti
+		if memo[key] == 0 {
+			memo[key] = 2
+			if match(pi, ti) {
+				memo[key] = 1
+			}
+		}
+		return memo[key] == 1
+	}
+
+	match = func(pi, ti int) bool {
+		for pi < len(pattern) {
+			switch c := pattern[pi]; c {
+			case '\\':
+				// An escaped character matches itself; a trailing backslash never matches.
+				pi++
+				if pi >= len(pattern) || ti >= len(text) || text[ti] != pattern[pi] {
+					return false
+				}
+				pi++
+				ti++
+
+			case '?':
+				if ti >= len(text) || (pathname && text[ti] == '/') {
+					return false
+				}
+				pi++
+				ti++
+
+			case '[':
+				if ti >= len(text) || (pathname && text[ti] == '/') {
+					return false
+				}
+				matched, next, ok := matchBracket(pattern, pi, text[ti])
+				if !ok {
+					// Unterminated bracket: match "[" literally.
+					if text[ti] != '[' {
+						return false
+					}
+					pi++
+					ti++
+					continue
+				}
+				if !matched {
+					return false
+				}
+				pi = next
+				ti++
+
+			case '*':
+				start := pi
+				for pi < len(pattern) && pattern[pi] == '*' {
+					pi++
+				}
+
+				isComponent := (start == 0 || pattern[start-1] == '/') && (pi == len(pattern) || pattern[pi] == '/')
+				if pathname && pi-start >= 2 && isComponent {
+					// "**" at the end matches everything that is left.
+					if pi == len(pattern) {
+						return true
+					}
+					// "**/" matches zero or more directories.
+					if try(pi+1, ti) {
+						return true
+					}
+					for i := ti; i < len(text); i++ {
+						if text[i] == '/' && try(pi+1, i+1) {
+							return true
+						}
+					}
+					return false
+				}
+
+				if pi == len(pattern) {
+					return !pathname || !strings.Contains(text[ti:], "/")
+				}
+				for i := ti; i <= len(text); i++ {
+					if try(pi, i) {
+						return true
+					}
+					if i < len(text) && pathname && text[i] == '/' {
+						return false
+					}
+				}
+				return false
+
+			default:
+				if ti >= len(text) || text[ti] != c {
+					return false
+				}
+				pi++
+				ti++
+			}
+		}
+		return ti == len(text)
+	}
+
+	return match(0, 0)
+}
+
+// matchBracket matches ch against the bracket expression starting at
+// pattern[start] (which is '['). It returns whether ch matched, the index
+// right after the closing ']', and false in ok if the bracket is not closed.
+func matchBracket(pattern string, start int, ch byte) (matched bool, next int, ok bool) {
+	i := start + 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	first := true
+	for i < len(pattern) {
+		c := pattern[i]
+		if c == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+
+		// Character classes such as [:alpha:].
+		if c == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if matchCharClass(pattern[i+2:i+2+end], ch) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		if c == '\\' {
+			i++
+			if i >= len(pattern) {
+				return false, 0, false
+			}
+			c = pattern[i]
+		}
+
+		// Ranges such as a-z.
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi := pattern[i+2]
+			i += 2
+			if hi == '\\' && i+1 < len(pattern) {
+				i++
+				hi = pattern[i]
+			}
+			if c <= ch && ch <= hi {
+				matched = true
+			}
+			i++
+			continue
+		}
+
+		if c == ch {
+			matched = true
+		}
+		i++
+	}
+
+	return false, 0, false
+}
+
+// matchCharClass reports whether ch belongs to the POSIX class name.
+func matchCharClass(name string, ch byte) bool {
+	r := rune(ch)
+	switch name {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return ch == ' ' || ch == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return unicode.IsDigit(r)
+	case "graph":
+		return unicode.IsGraphic(r) && ch != ' '
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return strings.IndexByte("0123456789abcdefABCDEF", ch) >= 0
+	}
+	return false
+}
+
+// hasWildcard reports whether pattern contains any wildcard character.
+func hasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
@@ -0,0 +1,209 @@
+package gogit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWildmatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		text     string
+		pathname bool
+		want     bool
+	}{
+		// Literals and basic wildcards.
+		{"foo", "foo", true, true},
+		{"bar", "foo", true, false},
+		{"", "", true, true},
+		{"???", "foo", true, true},
+		{"??", "foo", true, false},
+		{"*", "foo", true, true},
+		{"f*", "foo", true, true},
+		{"*f", "foo", true, false},
+		{"*foo*", "foo", true, true},
+		{"*ob*a*r*", "foobar", true, true},
+		{"*ab", "aaaaaaabababab", true, true},
+
+		// Escapes.
+		{`foo\*`, "foo*", true, true},
+		{`foo\*bar`, "foobar", true, false},
+		{`f\\oo`, `f\oo`, true, true},
+		{`foo\`, "foo", true, false},
+
+		// Bracket expressions.
+		{"*[al]?", "ball", true, true},
+		{"[ten]", "ten", true, false},
+		{"**[!te]", "ten", true, true},
+		{"t[a-g]n", "ten", true, true},
+		{"t[!a-g]n", "ten", true, false},
+		{"t[!a-g]n", "ton", true, true},
+		{"t[^a-g]n", "ton", true, true},
+		{"a[]]b", "a]b", true, true},
+		{"a[]-]b", "a-b", true, true},
+		{"a[]a-]b", "aab", true, true},
+		{"]", "]", true, true},
+		{`[\]]`, "]", true, true},
+		{`[\-]`, "-", true, true},
+		{"[[:alpha:]][[:digit:]][[:upper:]]", "a1B", true, true},
+		{"[[:digit:][:upper:][:space:]]", "a", true, false},
+		{"[[:digit:][:upper:][:space:]]", "A", true, true},
+		{"[[:xdigit:]]", "F", true, true},
+		{"[[:xdigit:]]", "g", true, false},
+
+		// Slashes: only crossed by "*" and "?" without pathname.
+		{"foo*bar", "foo/baz/bar", true, false},
+		{"foo*bar", "foo/baz/bar", false, true},
+		{"foo?bar", "foo/bar", true, false},
+		{"foo?bar", "foo/bar", false, true},
+		{"foo[/]bar", "foo/bar", true, false},
+		{"foo**bar", "foo/baz/bar", true, false},
+		{"*/foo", "bar/baz/foo", true, false},
+
+		// "**" components.
+		{"foo/**/bar", "foo/bar", true, true},
+		{"foo/**/bar", "foo/b/a/z/bar", true, true},
+		{"foo/**/bar", "foo/barx", true, false},
+		{"**/foo", "foo", true, true},
+		{"**/foo", "XXX/foo", true, true},
+		{"**/foo", "bar/baz/foo", true, true},
+		{"**/bar*", "deep/foo/bar/baz", true, false},
+		{"**/bar/*", "deep/foo/bar/baz", true, true},
+		{"**/bar/*", "deep/foo/bar/baz/", true, false},
+		{"**/bar/**", "deep/foo/bar/baz/", true, true},
+		{"foo/**", "foo/bar/baz", true, true},
+		{"foo/**", "foo", true, false},
+		{"**", "foo/bar", true, true},
+
+		// Many stars.
+		{"-*-*-*-*-*-*-12-*-*-*-m-*-*-*", "-adobe-courier-bold-o-normal--12-120-75-75-m-70-iso8859-1", true, true},
+		{"-*-*-*-*-*-*-12-*-*-*-m-*-*-*", "-adobe-courier-bold-o-normal--12-120-75-75-X-70-iso8859-1", true, false},
+		{"XXX/*/*/*/*/*/*/12/*/*/*/m/*/*/*", "XXX/adobe/courier/bold/o/normal//12/120/75/75/m/70/iso8859/1", true, true},
+		{"**/*a*b*g*n*t", "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txt", true, true},
+		{"**/*a*b*g*n*t", "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txtz", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := wildmatch(tt.pattern, tt.text, tt.pathname); got != tt.want {
+			t.Errorf("wildmatch(%q, %q, %v) = %v, want %v", tt.pattern, tt.text, tt.pathname, got, tt.want)
+		}
+	}
+}
+
+// TestWildmatchManyStars makes sure that failing patterns with many stars
+// do not backtrack exponentially; it would not finish otherwise.
+func TestWildmatchManyStars(t *testing.T) {
+	pattern := strings.Repeat("a*", 30) + "b"
+	text := strings.Repeat("a", 200)
+	for _, pathname := range []bool{true, false} {
+		if wildmatch(pattern, text, pathname) {
+			t.Errorf("wildmatch(%q, %q, %v) = true, want false", pattern, text, pathname)
+		}
+	}
+
+	pattern = "**/" + strings.Repeat("*a*/", 10) + "b"
+	text = strings.Repeat("aa/", 60)
+	if wildmatch(pattern, text, true) {
+		t.Errorf("wildmatch(%q, %q, true) = true, want false", pattern, text)
+	}
+}
+
+func TestPathspecMatch(t *testing.T) {
+	tests := []struct {
+		pathspecs []string
+		path      string
+		want      bool
+	}{
+		{nil, "any/file", true},
+		{[]string{"."}, "any/file", true},
+		{[]string{"src"}, "src/main.go", true},
+		{[]string{"src"}, "srcfile", false},
+		{[]string{"src/"}, "src/main.go", true},
+		{[]string{"*.go"}, "main.go", true},
+		{[]string{"*.go"}, "src/deep/main.go", true},
+		{[]string{"src/*.go"}, "src/deep/main.go", true},
+		{[]string{":(glob)src/*.go"}, "src/deep/main.go", false},
+		{[]string{":(glob)src/**/*.go"}, "src/deep/main.go", true},
+		{[]string{":(literal)*.go"}, "main.go", false},
+		{[]string{":(literal)*.go"}, "*.go", true},
+		{[]string{":(icase)README"}, "readme", true},
+		{[]string{":(icase)README"}, "docs/readme", false},
+		{[]string{"s?c"}, "src/main.go", true},
+		{[]string{"src", ":!src/vendor"}, "src/main.go", true},
+		{[]string{"src", ":!src/vendor"}, "src/vendor/lib.go", false},
+		{[]string{":(exclude)*.md"}, "README.md", false},
+		{[]string{":(exclude)*.md"}, "main.go", true},
+		{[]string{":^*.md"}, "main.go", true},
+		{[]string{":/docs"}, "docs/index.md", true},
+		{[]string{":(top)docs"}, "docs/index.md", true},
+		{[]string{"a", "b"}, "b/file", true},
+		{[]string{"a", "b"}, "c/file", false},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParsePathspec(tt.pathspecs)
+		if err != nil {
+			t.Errorf("ParsePathspec(%q): %v", tt.pathspecs, err)
+			continue
+		}
+		if got := spec.Match(tt.path); got != tt.want {
+			t.Errorf("pathspec %q matching %q = %v, want %v", tt.pathspecs, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParsePathspecErrors(t *testing.T) {
+	for _, arg := range []string{
+		":(top",
+		":(bogus)file",
+		":(literal,glob)file",
+		"../outside",
+	} {
+		if _, err := ParsePathspec([]string{arg}); err == nil {
+			t.Errorf("ParsePathspec(%q) succeeded, want an error", arg)
+		}
+	}
+}
+
+func TestPathspecUnmatched(t *testing.T) {
+	spec, err := ParsePathspec([]string{"src", "docs", ":!src/vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"src/main.go", "README.md"} {
+		spec.Match(path)
+	}
+
+	unmatched := spec.Unmatched()
+	if len(unmatched) != 1 || unmatched[0] != "docs" {
+		t.Errorf("Unmatched() = %q, want [docs]", unmatched)
+	}
+	if err := spec.CheckUnmatched(); err == nil {
+		t.Error("CheckUnmatched() = nil, want an error for docs")
+	}
+}
+
+func TestPathspecWalkRoots(t *testing.T) {
+	tests := []struct {
+		pathspecs []string
+		want      []string
+	}{
+		{nil, []string{"."}},
+		{[]string{"src", "src/deep", "docs"}, []string{"docs", "src"}},
+		{[]string{"src/*.go"}, []string{"src"}},
+		{[]string{"*.go"}, []string{"."}},
+		{[]string{":(icase)src"}, []string{"."}},
+		{[]string{":!vendor"}, []string{"."}},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParsePathspec(tt.pathspecs)
+		if err != nil {
+			t.Errorf("ParsePathspec(%q): %v", tt.pathspecs, err)
+			continue
+		}
+		if got := spec.WalkRoots(); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("WalkRoots() of %q = %q, want %q", tt.pathspecs, got, tt.want)
+		}
+	}
+}
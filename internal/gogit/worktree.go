@@ -0,0 +1,556 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A repository can have several working trees: the main one, which holds
+// the repository in .gogit, and linked worktrees created by WorktreeAdd.
+// Each linked worktree has its own directory .gogit/worktrees/<name> with
+// the files that belong to it:
+//
+//	HEAD       the checked out branch or commit
+//	index      its index
+//	commondir  the repository, relative to this directory ("../..")
+//	gitdir     the absolute path of the .gogit file of the worktree
+//	locked     present while the worktree is locked, holding the reason
+//
+// and its .gogit is a file "gogitdir: <path of that directory>" (see
+// SetupRepository). Objects, refs and the configuration are shared.
+
+// worktree describes a working tree of the repository.
+type worktree struct {
+	path       string // absolute path of the working tree
+	gitDir     string // absolute path of its directory in the repository
+	name       string // name under .gogit/worktrees, empty for the main one
+	head       HeadState
+	current    bool // the working tree of this command
+	bare       bool // the main one of a bare repository, with no files
+	locked     bool
+	lockReason string
+	prunable   string // why the worktree can be pruned, empty when it cannot
+}
+
+// main reports whether wt is the main working tree.
+func (wt *worktree) main() bool {
+	return wt.name == ""
+}
+
+// listWorktrees returns the main working tree, then the linked ones sorted
+// by name.
+func listWorktrees() ([]worktree, error) {
+	commonDir, err := filepath.Abs(CommonPath)
+	if err != nil {
+		return nil, err
+	}
+	gitDir, err := filepath.Abs(RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mainHead, err := readHeadFile(filepath.Join(commonDir, HEAD))
+	if err != nil {
+		return nil, err
+	}
+	// A bare repository has no working tree of its own; it stands for it.
+	mainPath := filepath.Dir(commonDir)
+	if bareRepository {
+		mainPath = commonDir
+	}
+	worktrees := []worktree{{
+		path:    mainPath,
+		gitDir:  commonDir,
+		head:    mainHead,
+		current: gitDir == commonDir,
+		bare:    bareRepository,
+	}}
+
+	entries, err := os.ReadDir(WorktreesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading %s: %w", WorktreesPath, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		wt := worktree{name: entry.Name(), gitDir: filepath.Join(commonDir, "worktrees", entry.Name())}
+		wt.current = wt.gitDir == gitDir
+		wt.head, _ = readHeadFile(filepath.Join(wt.gitDir, HEAD))
+
+		if reason, err := os.ReadFile(filepath.Join(wt.gitDir, "locked")); err == nil {
+			wt.locked, wt.lockReason = true, strings.TrimSpace(string(reason))
+		}
+
+		gitdirFile, err := os.ReadFile(filepath.Join(wt.gitDir, "gitdir"))
+		if err != nil {
+			wt.prunable = "gitdir file does not exist"
+		} else {
+			dotGogit := strings.TrimSpace(string(gitdirFile))
+			wt.path = filepath.Dir(dotGogit)
+			if _, err := os.Stat(dotGogit); err != nil {
+				wt.prunable = "gitdir file points to non-existent location"
+			}
+		}
+		worktrees = append(worktrees, wt)
+	}
+	return worktrees, nil
+}
+
+// findWorktree returns the working tree given on the command line: its
+// path, or the name of a linked worktree when that is unambiguous.
+func findWorktree(arg string) (*worktree, error) {
+	worktrees, err := listWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	if absPath, err := filepath.Abs(UserPath(arg)); err == nil {
+		for i := range worktrees {
+			if worktrees[i].path == absPath {
+				return &worktrees[i], nil
+			}
+		}
+	}
+
+	var found *worktree
+	for i := range worktrees {
+		wt := &worktrees[i]
+		if !wt.main() && (wt.name == arg || filepath.Base(wt.path) == arg) {
+			if found != nil {
+				return nil, fmt.Errorf("fatal: '%s' is ambiguous", arg)
+			}
+			found = wt
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("fatal: '%s' is not a working tree", arg)
+	}
+	return found, nil
+}
+
+// branchWorktree returns the working tree that has branch checked out, or
+// nil when none has.
+func branchWorktree(branch string) (*worktree, error) {
+	worktrees, err := listWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	for i := range worktrees {
+		// The HEAD of a bare repository checks nothing out.
+		if !worktrees[i].bare && worktrees[i].head.Branch == branch {
+			return &worktrees[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// inWorktree runs fn from the root of another working tree, with the
+// repository paths pointing at it.
+func inWorktree(dir, gitDir string, fn func() error) error {
+	previousDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	commonDir, err := filepath.Abs(CommonPath)
+	if err != nil {
+		return err
+	}
+	previousGitDir, previousCommonDir := RepoPath, CommonPath
+
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("error entering %s: %w", dir, err)
+	}
+	setRepoPaths(gitDir, commonDir)
+	reloadAttributes()
+	defer func() {
+		os.Chdir(previousDir)
+		setRepoPaths(previousGitDir, previousCommonDir)
+		reloadAttributes()
+	}()
+
+	return fn()
+}
+
+// WorktreeAddOptions controls WorktreeAdd.
+type WorktreeAddOptions struct {
+	// NewBranch creates a branch of that name at the commit checked out.
+	NewBranch string
+	// Detach checks out the commit without a branch.
+	Detach bool
+	// Force checks out a branch even when another worktree has it.
+	Force bool
+	// Lock locks the new worktree, with LockReason.
+	Lock       bool
+	LockReason string
+}
+
+// worktreeNameInvalid matches the characters not kept in worktree names.
+var worktreeNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WorktreeAdd creates a linked worktree at dir and checks out commitish in
+// it. A branch is checked out on it; any other revision detaches HEAD. When
+// commitish is empty a branch named after dir is checked out, created at
+// HEAD if it does not exist.
+func WorktreeAdd(dir, commitish string, opts WorktreeAddOptions) error {
+	if opts.LockReason != "" && !opts.Lock {
+		return fmt.Errorf("fatal: --reason requires --lock")
+	}
+	absPath, err := filepath.Abs(UserPath(dir))
+	if err != nil {
+		return err
+	}
+	// Only a missing or empty directory can become a worktree.
+	entries, err := os.ReadDir(absPath)
+	if (err == nil && len(entries) > 0) || (err != nil && !os.IsNotExist(err)) {
+		return fmt.Errorf("fatal: '%s' already exists", dir)
+	}
+
+	// Work out what to check out.
+	branch, newBranch := "", false
+	switch {
+	case opts.NewBranch != "":
+		branch, newBranch = opts.NewBranch, true
+	case opts.Detach:
+	case commitish == "":
+		branch = filepath.Base(absPath)
+		exists, err := CheckIfBranchExists(branch)
+		if err != nil {
+			return err
+		}
+		newBranch = !exists
+	default:
+		exists, err := CheckIfBranchExists(commitish)
+		if err != nil {
+			return err
+		}
+		if exists {
+			branch = commitish
+		}
+	}
+
+	start := HEAD
+	switch {
+	case branch != "" && !newBranch:
+		start = branch
+	case commitish != "":
+		start = commitish
+	}
+	commitHash, err := ResolveRevision(start)
+	if err != nil {
+		return fmt.Errorf("fatal: invalid reference: %s", start)
+	}
+	commit, err := ReadCommit(commitHash)
+	if err != nil {
+		return err
+	}
+
+	if newBranch {
+		if exists, err := CheckIfBranchExists(branch); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("fatal: a branch named '%s' already exists", branch)
+		}
+	} else if branch != "" && !opts.Force {
+		wt, err := branchWorktree(branch)
+		if err != nil {
+			return err
+		}
+		if wt != nil {
+			return fmt.Errorf("fatal: '%s' is already used by worktree at '%s'", branch, wt.path)
+		}
+	}
+
+	// The directory of the worktree in the repository.
+	commonDir, err := filepath.Abs(CommonPath)
+	if err != nil {
+		return err
+	}
+	baseName := worktreeNameInvalid.ReplaceAllString(filepath.Base(absPath), "-")
+	name := baseName
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(commonDir, "worktrees", name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s%d", baseName, i)
+	}
+	gitDir := filepath.Join(commonDir, "worktrees", name)
+
+	switch {
+	case newBranch:
+		fmt.Printf("Preparing worktree (new branch '%s')\n", branch)
+	case branch != "":
+		fmt.Printf("Preparing worktree (checking out '%s')\n", branch)
+	default:
+		fmt.Printf("Preparing worktree (detached HEAD %s)\n", ShortHash(commitHash))
+	}
+
+	created := false
+	defer func() {
+		if !created {
+			os.RemoveAll(gitDir)
+			os.RemoveAll(absPath)
+		}
+	}()
+
+	head := commitHash + "\n"
+	if branch != "" {
+		head = fmt.Sprintf("ref: refs/heads/%s\n", branch)
+	}
+	// Locked while it is set up, so that a concurrent prune leaves it alone.
+	files := []struct{ name, content string }{
+		{"locked", "initializing\n"},
+		{"commondir", filepath.Join("..", "..") + "\n"},
+		{"gitdir", filepath.Join(absPath, ROOT) + "\n"},
+		{HEAD, head},
+	}
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", gitDir, err)
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(gitDir, file.name), []byte(file.content), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", file.name, err)
+		}
+	}
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(absPath, ROOT), []byte(gitdirPrefix+" "+gitDir+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", filepath.Join(dir, ROOT), err)
+	}
+	if newBranch {
+		if err := writeLockedFile(filepath.Join(RefHeadsPath, branch), []byte(commitHash+"\n")); err != nil {
+			return fmt.Errorf("error creating branch '%s': %w", branch, err)
+		}
+	}
+
+	// Check out the files and fill the index of the new worktree.
+	err = inWorktree(absPath, gitDir, func() error {
+		tree, err := ReadTree(commit.Tree)
+		if err != nil {
+			return err
+		}
+		indexLock, err := LockIndex()
+		if err != nil {
+			return err
+		}
+		defer indexLock.Rollback()
+
+		if err := ApplyDiffCheckout(nil, tree, nil); err != nil {
+			return fmt.Errorf("error checking out the files: %w", err)
+		}
+		return WriteIndex(indexLock, TreeToIndex(tree))
+	})
+	if err != nil {
+		if newBranch {
+			os.Remove(filepath.Join(RefHeadsPath, branch))
+		}
+		return err
+	}
+
+	lockPath := filepath.Join(gitDir, "locked")
+	if opts.Lock {
+		err = os.WriteFile(lockPath, []byte(opts.LockReason+"\n"), 0644)
+	} else {
+		err = os.Remove(lockPath)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating %s: %w", lockPath, err)
+	}
+	created = true
+
+	fmt.Printf("HEAD is now at %s %s\n", ShortHash(commitHash), firstLine(commit.Message))
+	return nil
+}
+
+// WorktreeList prints the working trees: their path, commit and branch, and
+// whether they are locked or can be pruned; a bare repository is listed as
+// "(bare)". The porcelain format has one
+// "<attribute> <value>" line per attribute and a blank line after each
+// working tree.
+func WorktreeList(porcelain bool) error {
+	worktrees, err := listWorktrees()
+	if err != nil {
+		return err
+	}
+
+	if porcelain {
+		for _, wt := range worktrees {
+			fmt.Printf("worktree %s\n", wt.path)
+			if wt.bare {
+				fmt.Print("bare\n\n")
+				continue
+			}
+			fmt.Printf("HEAD %s\n", porcelainHash(wt.head.Hash))
+			if wt.head.Detached() {
+				fmt.Println("detached")
+			} else {
+				fmt.Printf("branch refs/heads/%s\n", wt.head.Branch)
+			}
+			if wt.locked {
+				fmt.Println(strings.TrimSpace("locked " + wt.lockReason))
+			}
+			if wt.prunable != "" {
+				fmt.Printf("prunable %s\n", wt.prunable)
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	width := 0
+	for _, wt := range worktrees {
+		width = max(width, len(wt.path))
+	}
+	for _, wt := range worktrees {
+		if wt.bare {
+			fmt.Printf("%-*s (bare)\n", width, wt.path)
+			continue
+		}
+		line := fmt.Sprintf("%-*s %-7s ", width, wt.path, ShortHash(porcelainHash(wt.head.Hash)))
+		if wt.head.Detached() {
+			line += "(detached HEAD)"
+		} else {
+			line += "[" + wt.head.Branch + "]"
+		}
+		if wt.locked {
+			line += " locked"
+		}
+		if wt.prunable != "" {
+			line += " prunable"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// WorktreeRemove deletes a linked worktree. A worktree with modified or
+// untracked files needs force, and a locked one force twice.
+func WorktreeRemove(arg string, force int) error {
+	wt, err := findWorktree(arg)
+	if err != nil {
+		return err
+	}
+	switch {
+	case wt.main():
+		return fmt.Errorf("fatal: '%s' is a main working tree", arg)
+	case wt.current:
+		return fmt.Errorf("fatal: cannot remove the current working tree")
+	case wt.locked && force < 2 && wt.lockReason != "":
+		return fmt.Errorf("fatal: cannot remove a locked working tree, lock reason: %s\nuse 'remove -f -f' to override or unlock first", wt.lockReason)
+	case wt.locked && force < 2:
+		return fmt.Errorf("fatal: cannot remove a locked working tree;\nuse 'remove -f -f' to override or unlock first")
+	}
+
+	_, statErr := os.Stat(wt.path)
+	if force == 0 && statErr == nil {
+		var dirty bool
+		err := inWorktree(wt.path, wt.gitDir, func() error {
+			spec, err := ParsePathspec(nil)
+			if err != nil {
+				return err
+			}
+			statusInfo, err := collectStatus(spec, StatusOptions{Untracked: UntrackedAll})
+			if err != nil {
+				return err
+			}
+			dirty = len(statusInfo.Entries) > 0
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("fatal: '%s' contains modified or untracked files, use --force to delete it", arg)
+		}
+	}
+
+	if err := os.RemoveAll(wt.path); err != nil {
+		return fmt.Errorf("error removing %s: %w", wt.path, err)
+	}
+	return removeWorktreeDir(wt)
+}
+
+// removeWorktreeDir deletes the directory of a linked worktree in the
+// repository, and the worktrees directory once it is empty.
+func removeWorktreeDir(wt *worktree) error {
+	if err := os.RemoveAll(wt.gitDir); err != nil {
+		return fmt.Errorf("error removing %s: %w", wt.gitDir, err)
+	}
+	os.Remove(WorktreesPath)
+	return nil
+}
+
+// WorktreePruneOptions controls WorktreePrune.
+type WorktreePruneOptions struct {
+	// DryRun only reports what would be removed.
+	DryRun bool
+	// Verbose reports what is removed.
+	Verbose bool
+}
+
+// WorktreePrune deletes the administrative files of the linked worktrees
+// whose working tree is gone, unless they are locked.
+func WorktreePrune(opts WorktreePruneOptions) error {
+	worktrees, err := listWorktrees()
+	if err != nil {
+		return err
+	}
+
+	for i := range worktrees {
+		wt := &worktrees[i]
+		if wt.main() || wt.locked || wt.prunable == "" {
+			continue
+		}
+		if opts.Verbose || opts.DryRun {
+			fmt.Printf("Removing worktrees/%s: %s\n", wt.name, wt.prunable)
+		}
+		if opts.DryRun {
+			continue
+		}
+		if err := removeWorktreeDir(wt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorktreeLock prevents a linked worktree from being pruned or removed, for
+// instance while it is on a removable disk.
+func WorktreeLock(arg, reason string) error {
+	wt, err := findWorktree(arg)
+	if err != nil {
+		return err
+	}
+	if wt.main() {
+		return fmt.Errorf("fatal: the main working tree cannot be locked or unlocked")
+	}
+	if wt.locked {
+		if wt.lockReason != "" {
+			return fmt.Errorf("fatal: '%s' is already locked, reason: %s", arg, wt.lockReason)
+		}
+		return fmt.Errorf("fatal: '%s' is already locked", arg)
+	}
+	return writeLockedFile(filepath.Join(wt.gitDir, "locked"), []byte(reason+"\n"))
+}
+
+// WorktreeUnlock lifts the lock of a linked worktree.
+func WorktreeUnlock(arg string) error {
+	wt, err := findWorktree(arg)
+	if err != nil {
+		return err
+	}
+	if wt.main() {
+		return fmt.Errorf("fatal: the main working tree cannot be locked or unlocked")
+	}
+	if !wt.locked {
+		return fmt.Errorf("fatal: '%s' is not locked", arg)
+	}
+	if err := os.Remove(filepath.Join(wt.gitDir, "locked")); err != nil {
+		return fmt.Errorf("error unlocking '%s': %w", arg, err)
+	}
+	return nil
+}
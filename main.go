@@ -4,11 +4,14 @@ import (
 	"os"
 
 	"github.com/TonyGLL/gogit/cmd/cli"
+	"github.com/TonyGLL/gogit/internal/gogit"
 )
 
 func main() {
 	cmd := cli.NewRootCmd()
 	if err := cmd.Execute(); err != nil {
+		// os.Exit skips deferred work, so the filters are stopped here.
+		gogit.StopFilterProcesses()
 		os.Exit(1)
 	}
 }